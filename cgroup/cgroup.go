@@ -1,9 +1,13 @@
 package cgroup
 
 import (
+	"os"
+	"strings"
+
 	"github.com/liruonian/basin/cgroup/subsystem"
 	"github.com/liruonian/basin/common"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 type Manager struct {
@@ -27,21 +31,82 @@ func (c *Manager) Set(config *common.CgroupParam) error {
 	return nil
 }
 
+// Apply 将进程加入各子系统的cgroup，某个子系统失败时仍会尝试其余子系统，并返回汇总后的错误
 func (c *Manager) Apply(pid int, config *common.CgroupParam) error {
+	var messages []string
 	for _, supportedSubSystem := range subsystem.SupportedSubSystems {
 		err := supportedSubSystem.Apply(c.Path, pid, config)
+		// 未挂载的子系统若设置了限制，Set时已返回错误，此处仅是无法统计该子系统的资源
+		if errors.Cause(err) == subsystem.ErrNotMounted {
+			logrus.Warnf("skip cgroup subsystem[%s]: %v", supportedSubSystem.Name(), err)
+			continue
+		}
 		if err != nil {
-			return errors.Wrapf(err, "apply subsystem[%s] failed", supportedSubSystem.Name())
+			messages = append(messages, errors.Wrapf(err, "apply subsystem[%s] failed", supportedSubSystem.Name()).Error())
 		}
 	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
 	return nil
 }
 
 func (c *Manager) Destroy() error {
 	for _, supportedSubSystem := range subsystem.SupportedSubSystems {
-		if err := supportedSubSystem.Remove(c.Path); err != nil {
+		if err := supportedSubSystem.Remove(c.Path); err != nil && errors.Cause(err) != subsystem.ErrNotMounted {
 			return errors.Wrapf(err, "remove cgroup %s failed", supportedSubSystem.Name())
 		}
 	}
 	return nil
 }
+
+// Pids 返回cgroup中的全部进程号
+func (c *Manager) Pids() ([]int, error) {
+	pids := &subsystem.PidsSubSystem{}
+	procs, err := pids.Procs(c.Path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read subsystem[%s] procs failed", pids.Name())
+	}
+	return procs, nil
+}
+
+// Freeze 冻结cgroup中的全部进程
+func (c *Manager) Freeze() error {
+	freezer := &subsystem.FreezerSubSystem{}
+	if err := freezer.Freeze(c.Path); err != nil {
+		return errors.Wrapf(err, "freeze subsystem[%s] failed", freezer.Name())
+	}
+	return nil
+}
+
+// Thaw 解冻cgroup中的全部进程
+func (c *Manager) Thaw() error {
+	freezer := &subsystem.FreezerSubSystem{}
+	if err := freezer.Thaw(c.Path); err != nil {
+		return errors.Wrapf(err, "thaw subsystem[%s] failed", freezer.Name())
+	}
+	return nil
+}
+
+// GetStats 汇总各子系统统计的资源使用情况，cgroup v1的子系统未挂载时从cgroup v2读取。
+// 无法读取的部分保持为nil，全部无法读取时返回错误，避免将无法统计误报为没有使用资源
+func (c *Manager) GetStats() (*subsystem.Stats, error) {
+	stats := &subsystem.Stats{}
+	for _, supportedSubSystem := range subsystem.SupportedSubSystems {
+		err := supportedSubSystem.GetStats(c.Path, stats)
+		if os.IsNotExist(errors.Cause(err)) {
+			logrus.Debugf("skip subsystem[%s] stats: %v", supportedSubSystem.Name(), err)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get subsystem[%s] stats failed", supportedSubSystem.Name())
+		}
+	}
+	if err := subsystem.GetCgroup2Stats(c.Path, stats); err != nil {
+		return nil, errors.Wrap(err, "get cgroup2 stats failed")
+	}
+	if stats.Cpu == nil && stats.Memory == nil && stats.Pids == nil && stats.Blkio == nil {
+		return nil, errors.Errorf("no cgroup statistics available for %s", c.Path)
+	}
+	return stats, nil
+}
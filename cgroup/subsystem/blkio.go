@@ -0,0 +1,81 @@
+package subsystem
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// BlkioSubSystem 不做资源限制，容器进程总是加入该子系统，用于统计块设备读写量
+type BlkioSubSystem struct {
+}
+
+func (s *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+func (s *BlkioSubSystem) Set(cgroupPath string, config *common.CgroupParam) error {
+	return nil
+}
+
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
+	}
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+		return errors.Wrapf(err, "set cgroup proc failed")
+	}
+	return nil
+}
+
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsystemCgroupPath)
+}
+
+// GetStats 汇总各块设备的读写字节数，每行格式为`major:minor Read|Write|... bytes`
+func (s *BlkioSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := statsCgroupPath(s.Name(), cgroupPath)
+	if err != nil || subsystemCgroupPath == "" {
+		return err
+	}
+	serviceBytesFile, err := os.Open(path.Join(subsystemCgroupPath, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return errors.Wrapf(err, "read blkio service bytes failed")
+	}
+	defer serviceBytesFile.Close()
+
+	blkio := &BlkioStats{}
+	scanner := bufio.NewScanner(serviceBytesFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			blkio.ReadBytes += value
+		case "Write":
+			blkio.WriteBytes += value
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.Wrapf(err, "read blkio service bytes failed")
+	}
+	stats.Blkio = blkio
+	return nil
+}
@@ -0,0 +1,140 @@
+package subsystem
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// cgroup2Path 返回容器在cgroup v2中的路径，未挂载cgroup v2时返回ErrNotMounted
+func cgroup2Path(cgroupPath string) (string, error) {
+	cgroupRoot := findCgroup2Mountpoint()
+	if cgroupRoot == "" {
+		return "", errors.Wrap(ErrNotMounted, "cgroup2")
+	}
+	return path.Join(cgroupRoot, cgroupPath), nil
+}
+
+// GetCgroup2Stats 从cgroup v2中读取stats里尚未由cgroup v1子系统填充的部分。
+// 仅在未挂载freezer子系统时容器进程才会加入cgroup v2；父cgroup未启用对应的控制器时缺少统计文件，该部分保持为nil
+func GetCgroup2Stats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := cgroup2Path(cgroupPath)
+	if errors.Cause(err) == ErrNotMounted {
+		return nil
+	}
+	if _, err = os.Stat(subsystemCgroupPath); os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "stat cgroup %s", subsystemCgroupPath)
+	}
+
+	if stats.Cpu == nil {
+		values, err := readKeyValues(subsystemCgroupPath, "cpu.stat")
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "read cpu.stat failed")
+		}
+		if err == nil {
+			stats.Cpu = &CpuStats{
+				UsageNanos:       values["usage_usec"] * 1000,
+				Periods:          values["nr_periods"],
+				ThrottledPeriods: values["nr_throttled"],
+				ThrottledNanos:   values["throttled_usec"] * 1000,
+			}
+		}
+	}
+	if stats.Memory == nil {
+		if err = readCgroup2Memory(subsystemCgroupPath, stats); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+	}
+	if stats.Pids == nil {
+		if err = readCgroup2Pids(subsystemCgroupPath, stats); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+	}
+	if stats.Blkio == nil {
+		if err = readCgroup2Io(subsystemCgroupPath, stats); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+	}
+	return nil
+}
+
+func readCgroup2Memory(subsystemCgroupPath string, stats *Stats) error {
+	var err error
+	memory := &MemoryStats{}
+	if memory.Usage, err = readUint(subsystemCgroupPath, "memory.current"); err != nil {
+		return errors.Wrapf(err, "read memory current failed")
+	}
+	if memory.Limit, err = readUint(subsystemCgroupPath, "memory.max"); err != nil {
+		return errors.Wrapf(err, "read memory max failed")
+	}
+	// memory.peak自5.19版本的内核起提供
+	if memory.MaxUsage, err = readUint(subsystemCgroupPath, "memory.peak"); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "read memory peak failed")
+	}
+	values, err := readKeyValues(subsystemCgroupPath, "memory.stat")
+	if err != nil {
+		return errors.Wrapf(err, "read memory.stat failed")
+	}
+	memory.Cache = values["file"]
+	stats.Memory = memory
+	return nil
+}
+
+func readCgroup2Pids(subsystemCgroupPath string, stats *Stats) error {
+	var err error
+	pids := &PidsStats{}
+	if pids.Current, err = readUint(subsystemCgroupPath, "pids.current"); err != nil {
+		return errors.Wrapf(err, "read pids current failed")
+	}
+	if pids.Limit, err = readUint(subsystemCgroupPath, "pids.max"); err != nil {
+		return errors.Wrapf(err, "read pids max failed")
+	}
+	stats.Pids = pids
+	return nil
+}
+
+// readCgroup2Io 汇总各块设备的读写字节数，每行格式为`major:minor rbytes=... wbytes=... rios=...`
+func readCgroup2Io(subsystemCgroupPath string, stats *Stats) error {
+	ioStatFile, err := os.Open(path.Join(subsystemCgroupPath, "io.stat"))
+	if err != nil {
+		return errors.Wrapf(err, "read io.stat failed")
+	}
+	defer ioStatFile.Close()
+
+	blkio := &BlkioStats{}
+	scanner := bufio.NewScanner(ioStatFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				blkio.ReadBytes += value
+			case "wbytes":
+				blkio.WriteBytes += value
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.Wrapf(err, "read io.stat failed")
+	}
+	stats.Blkio = blkio
+	return nil
+}
@@ -39,7 +39,7 @@ func (s *CpuSubSystem) Set(cgroupPath string, config *common.CgroupParam) error
 	}
 
 	if config.CpuCfsQuota != 0 {
-		if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, "cpu.cfs_period_us"), []byte(string(PeriodDefault)), common.Perm0644); err != nil {
+		if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, "cpu.cfs_period_us"), []byte(strconv.Itoa(PeriodDefault)), common.Perm0644); err != nil {
 			return errors.Wrapf(err, "set cgroup cpu period us failed")
 		}
 		if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.Itoa(PeriodDefault/Percent*config.CpuCfsQuota)), common.Perm0644); err != nil {
@@ -49,16 +49,13 @@ func (s *CpuSubSystem) Set(cgroupPath string, config *common.CgroupParam) error
 	return nil
 }
 
+// Apply 未设置限制时也将进程加入cgroup，以便统计cpu节流情况
 func (s *CpuSubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
-	if config.CpuCfsQuota == 0 && config.CpuShare == "" {
-		return nil
-	}
-
-	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return errors.Wrapf(err, "get cgroup %s failed", cgroupPath)
 	}
-	if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+	if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
 		return errors.Wrapf(err, "set cgroup proc failed")
 	}
 	return nil
@@ -71,3 +68,21 @@ func (s *CpuSubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsystemCgroupPath)
 }
+
+func (s *CpuSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := statsCgroupPath(s.Name(), cgroupPath)
+	if err != nil || subsystemCgroupPath == "" {
+		return err
+	}
+	values, err := readKeyValues(subsystemCgroupPath, "cpu.stat")
+	if err != nil {
+		return errors.Wrapf(err, "read cpu.stat failed")
+	}
+	if stats.Cpu == nil {
+		stats.Cpu = &CpuStats{}
+	}
+	stats.Cpu.Periods = values["nr_periods"]
+	stats.Cpu.ThrottledPeriods = values["nr_throttled"]
+	stats.Cpu.ThrottledNanos = values["throttled_time"]
+	return nil
+}
@@ -0,0 +1,58 @@
+package subsystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// CpuacctSubSystem 不做资源限制，容器进程总是加入该子系统，用于统计cpu使用时间
+type CpuacctSubSystem struct {
+}
+
+func (s *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
+
+func (s *CpuacctSubSystem) Set(cgroupPath string, config *common.CgroupParam) error {
+	return nil
+}
+
+func (s *CpuacctSubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
+	}
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+		return errors.Wrapf(err, "set cgroup proc failed")
+	}
+	return nil
+}
+
+func (s *CpuacctSubSystem) Remove(cgroupPath string) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsystemCgroupPath)
+}
+
+func (s *CpuacctSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := statsCgroupPath(s.Name(), cgroupPath)
+	if err != nil || subsystemCgroupPath == "" {
+		return err
+	}
+	usage, err := readUint(subsystemCgroupPath, "cpuacct.usage")
+	if err != nil {
+		return errors.Wrapf(err, "read cpuacct usage failed")
+	}
+	if stats.Cpu == nil {
+		stats.Cpu = &CpuStats{}
+	}
+	stats.Cpu.UsageNanos = usage
+	return nil
+}
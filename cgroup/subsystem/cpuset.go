@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/liruonian/basin/common"
 	"github.com/pkg/errors"
@@ -26,6 +27,9 @@ func (s *CpusetSubSystem) Set(cgroupPath string, config *common.CgroupParam) err
 	if err != nil {
 		return err
 	}
+	if err = inheritCpuset(findCgroupMountpoint(s.Name()), cgroupPath); err != nil {
+		return err
+	}
 	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cpuset.cpus"), []byte(config.CpuSet), common.Perm0644); err != nil {
 		return errors.Wrapf(err, "set cgroup cpuset failed")
 	}
@@ -42,7 +46,7 @@ func (s *CpusetSubSystem) Apply(cgroupPath string, pid int, config *common.Cgrou
 		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
 
 	}
-	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
 		return errors.Wrapf(err, "set cgroup proc failed")
 	}
 	return nil
@@ -55,3 +59,33 @@ func (s *CpusetSubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsystemCgroupPath)
 }
+
+func (s *CpusetSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	return nil
+}
+
+// inheritCpuset 新建的cpuset cgroup中cpus及mems为空，此时无法设置cpus也无法加入进程，
+// 因此从挂载点开始逐级为空的cgroup继承父cgroup的设置
+func inheritCpuset(cgroupRoot string, cgroupPath string) error {
+	parent := cgroupRoot
+	for _, name := range strings.Split(strings.Trim(cgroupPath, "/"), "/") {
+		current := path.Join(parent, name)
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			contentBytes, err := ioutil.ReadFile(path.Join(current, file))
+			if err != nil {
+				return errors.Wrapf(err, "read %s", file)
+			}
+			if strings.TrimSpace(string(contentBytes)) != "" {
+				continue
+			}
+			if contentBytes, err = ioutil.ReadFile(path.Join(parent, file)); err != nil {
+				return errors.Wrapf(err, "read %s", file)
+			}
+			if err = ioutil.WriteFile(path.Join(current, file), contentBytes, common.Perm0644); err != nil {
+				return errors.Wrapf(err, "inherit %s", file)
+			}
+		}
+		parent = current
+	}
+	return nil
+}
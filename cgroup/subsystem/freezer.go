@@ -0,0 +1,125 @@
+package subsystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// freezeTimeout 等待cgroup中的进程全部冻结或解冻的超时时间
+	freezeTimeout = 5 * time.Second
+	// freezeInterval 轮询冻结状态的间隔
+	freezeInterval = 10 * time.Millisecond
+)
+
+// FreezerSubSystem 不做资源限制，容器进程总是加入该子系统，用于暂停及恢复容器内的全部进程。
+// 优先使用cgroup v1的freezer子系统，不存在时使用cgroup v2的cgroup.freeze
+type FreezerSubSystem struct {
+}
+
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+func (s *FreezerSubSystem) Set(cgroupPath string, config *common.CgroupParam) error {
+	return nil
+}
+
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
+	subsystemCgroupPath, _, err := s.cgroupPath(cgroupPath, true)
+	if err != nil {
+		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
+	}
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+		return errors.Wrapf(err, "set cgroup proc failed")
+	}
+	return nil
+}
+
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	subsystemCgroupPath, _, err := s.cgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsystemCgroupPath)
+}
+
+// Freeze 冻结cgroup中的全部进程，并等待冻结完成
+func (s *FreezerSubSystem) Freeze(cgroupPath string) error {
+	return s.setFrozen(cgroupPath, true)
+}
+
+// Thaw 解冻cgroup中的全部进程
+func (s *FreezerSubSystem) Thaw(cgroupPath string) error {
+	return s.setFrozen(cgroupPath, false)
+}
+
+func (s *FreezerSubSystem) setFrozen(cgroupPath string, frozen bool) error {
+	subsystemCgroupPath, v2, err := s.cgroupPath(cgroupPath, false)
+	if err != nil {
+		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
+	}
+
+	// v1写入freezer.state后状态可能为FREEZING，v2需从cgroup.events中读取冻结结果
+	stateFile, state, expected := "freezer.state", "THAWED", "THAWED"
+	if frozen {
+		state, expected = "FROZEN", "FROZEN"
+	}
+	if v2 {
+		stateFile, state, expected = "cgroup.freeze", "0", "frozen 0"
+		if frozen {
+			state, expected = "1", "frozen 1"
+		}
+	}
+	if err = ioutil.WriteFile(path.Join(subsystemCgroupPath, stateFile), []byte(state), common.Perm0644); err != nil {
+		return errors.Wrapf(err, "write %s failed", stateFile)
+	}
+
+	if v2 {
+		stateFile = "cgroup.events"
+	}
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		contentBytes, err := ioutil.ReadFile(path.Join(subsystemCgroupPath, stateFile))
+		if err != nil {
+			return errors.Wrapf(err, "read %s failed", stateFile)
+		}
+		if strings.Contains(string(contentBytes), expected) {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return errors.Errorf("cgroup %s did not reach %s in %v", cgroupPath, expected, freezeTimeout)
+		}
+		time.Sleep(freezeInterval)
+	}
+}
+
+// cgroupPath 返回容器在freezer层级中的路径，以及是否为cgroup v2
+func (s *FreezerSubSystem) cgroupPath(cgroupPath string, autoCreate bool) (string, bool, error) {
+	if findCgroupMountpoint(s.Name()) != "" {
+		subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, autoCreate)
+		return subsystemCgroupPath, false, err
+	}
+
+	absPath, err := cgroup2Path(cgroupPath)
+	if err != nil {
+		return "", false, errors.Wrap(ErrNotMounted, "neither freezer nor cgroup2")
+	}
+	if autoCreate {
+		if err := os.MkdirAll(absPath, common.Perm0755); err != nil {
+			return "", true, err
+		}
+	}
+	return absPath, true, nil
+}
+
+func (s *FreezerSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	return nil
+}
@@ -33,15 +33,13 @@ func (s *MemorySubSystem) Set(cgroupPath string, config *common.CgroupParam) err
 	return nil
 }
 
+// Apply 未设置限制时也将进程加入cgroup，以便统计内存使用情况
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
-	if config.MemoryLimit == "" {
-		return nil
-	}
-	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
 	}
-	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
 		return errors.Wrapf(err, "set cgroup proc failed")
 	}
 	return nil
@@ -54,3 +52,27 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsystemCgroupPath)
 }
+
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := statsCgroupPath(s.Name(), cgroupPath)
+	if err != nil || subsystemCgroupPath == "" {
+		return err
+	}
+	memory := &MemoryStats{}
+	if memory.Usage, err = readUint(subsystemCgroupPath, "memory.usage_in_bytes"); err != nil {
+		return errors.Wrapf(err, "read memory usage failed")
+	}
+	if memory.MaxUsage, err = readUint(subsystemCgroupPath, "memory.max_usage_in_bytes"); err != nil {
+		return errors.Wrapf(err, "read memory max usage failed")
+	}
+	if memory.Limit, err = readUint(subsystemCgroupPath, "memory.limit_in_bytes"); err != nil {
+		return errors.Wrapf(err, "read memory limit failed")
+	}
+	values, err := readKeyValues(subsystemCgroupPath, "memory.stat")
+	if err != nil {
+		return errors.Wrapf(err, "read memory.stat failed")
+	}
+	memory.Cache = values["total_cache"]
+	stats.Memory = memory
+	return nil
+}
@@ -0,0 +1,87 @@
+package subsystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// PidsSubSystem 不做资源限制，容器进程总是加入该子系统，用于跟踪容器内的全部进程
+type PidsSubSystem struct {
+}
+
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+func (s *PidsSubSystem) Set(cgroupPath string, config *common.CgroupParam) error {
+	return nil
+}
+
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int, config *common.CgroupParam) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
+	}
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), common.Perm0644); err != nil {
+		return errors.Wrapf(err, "set cgroup proc failed")
+	}
+	return nil
+}
+
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsystemCgroupPath)
+}
+
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsystemCgroupPath, err := statsCgroupPath(s.Name(), cgroupPath)
+	if err != nil || subsystemCgroupPath == "" {
+		return err
+	}
+	pids := &PidsStats{}
+	if pids.Current, err = readUint(subsystemCgroupPath, "pids.current"); err != nil {
+		return errors.Wrapf(err, "read pids current failed")
+	}
+	if pids.Limit, err = readUint(subsystemCgroupPath, "pids.max"); err != nil {
+		return errors.Wrapf(err, "read pids max failed")
+	}
+	stats.Pids = pids
+	return nil
+}
+
+// Procs 返回cgroup中的全部进程号。未挂载pids子系统时从freezer所在的层级读取，容器进程总会加入该层级
+func (s *PidsSubSystem) Procs(cgroupPath string) ([]int, error) {
+	subsystemCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if errors.Cause(err) == ErrNotMounted {
+		subsystemCgroupPath, _, err = (&FreezerSubSystem{}).cgroupPath(cgroupPath, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	contentBytes, err := ioutil.ReadFile(path.Join(subsystemCgroupPath, "cgroup.procs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read cgroup procs failed")
+	}
+
+	var pids []int
+	for _, line := range strings.Fields(string(contentBytes)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse pid %s", line)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
@@ -0,0 +1,100 @@
+package subsystem
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Stats cgroup中进程的资源使用情况，由各子系统分别填充。无法读取的部分为nil，不以0代替
+type Stats struct {
+	Cpu    *CpuStats    `json:"cpu"`
+	Memory *MemoryStats `json:"memory"`
+	Pids   *PidsStats   `json:"pids"`
+	Blkio  *BlkioStats  `json:"blkio"`
+}
+
+type CpuStats struct {
+	// UsageNanos 累计使用的cpu时间，单位为纳秒
+	UsageNanos       uint64 `json:"usageNanos"`
+	Periods          uint64 `json:"periods"`
+	ThrottledPeriods uint64 `json:"throttledPeriods"`
+	ThrottledNanos   uint64 `json:"throttledNanos"`
+}
+
+type MemoryStats struct {
+	Usage    uint64 `json:"usage"`
+	MaxUsage uint64 `json:"maxUsage"`
+	Limit    uint64 `json:"limit"`
+	Cache    uint64 `json:"cache"`
+}
+
+type PidsStats struct {
+	Current uint64 `json:"current"`
+	// Limit 为0时表示不限制
+	Limit uint64 `json:"limit"`
+}
+
+type BlkioStats struct {
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+}
+
+// readUint 读取只包含一个整数的cgroup文件，"max"视为0
+func readUint(dir, file string) (uint64, error) {
+	contentBytes, err := ioutil.ReadFile(path.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	content := strings.TrimSpace(string(contentBytes))
+	if content == "max" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse %s", file)
+	}
+	return value, nil
+}
+
+// readKeyValues 读取每行为`key value`格式的cgroup文件，如cpu.stat及memory.stat
+func readKeyValues(dir, file string) (map[string]uint64, error) {
+	statFile, err := os.Open(path.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer statFile.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(statFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, scanner.Err()
+}
+
+// statsCgroupPath 返回子系统中容器cgroup的路径，子系统未挂载或容器未加入该子系统时返回空串
+func statsCgroupPath(subsystem, cgroupPath string) (string, error) {
+	subsystemCgroupPath, err := GetCgroupPath(subsystem, cgroupPath, false)
+	if errors.Cause(err) == ErrNotMounted {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(subsystemCgroupPath); os.IsNotExist(err) {
+		return "", nil
+	}
+	return subsystemCgroupPath, nil
+}
@@ -2,11 +2,14 @@ package subsystem
 
 import (
 	"bufio"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,16 +18,29 @@ type Subsystem interface {
 	Set(path string, config *common.CgroupParam) error
 	Apply(path string, pid int, config *common.CgroupParam) error
 	Remove(path string) error
+	// GetStats 读取cgroup中进程的资源使用情况，并填充到stats中对应的部分
+	GetStats(path string, stats *Stats) error
 }
 
 var SupportedSubSystems = []Subsystem{
 	&CpusetSubSystem{},
 	&MemorySubSystem{},
 	&CpuSubSystem{},
+	&CpuacctSubSystem{},
+	&BlkioSubSystem{},
+	&PidsSubSystem{},
+	&FreezerSubSystem{},
 }
 
+// ErrNotMounted 子系统未挂载，此时无法通过该子系统限制或统计容器的资源
+var ErrNotMounted = errors.New("cgroup subsystem is not mounted")
+
 func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
 	cgroupRoot := findCgroupMountpoint(subsystem)
+	if cgroupRoot == "" {
+		// 未找到挂载点时拼接出的是相对路径，不能在当前目录下创建cgroup目录
+		return "", errors.Wrap(ErrNotMounted, subsystem)
+	}
 	absPath := path.Join(cgroupRoot, cgroupPath)
 	if !autoCreate {
 		return absPath, nil
@@ -32,7 +48,7 @@ func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string
 
 	_, err := os.Stat(absPath)
 	if err != nil && os.IsNotExist(err) {
-		err = os.Mkdir(absPath, common.Perm0755)
+		err = os.MkdirAll(absPath, common.Perm0755)
 		return absPath, err
 	}
 
@@ -65,3 +81,60 @@ func findCgroupMountpoint(subsystem string) string {
 	}
 	return ""
 }
+
+// findCgroup2Mountpoint 返回cgroup v2的挂载点，mountinfo中" - "之后的第一个字段为文件系统类型
+func findCgroup2Mountpoint() string {
+	mountinfoFile, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer mountinfoFile.Close()
+
+	scanner := bufio.NewScanner(mountinfoFile)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[1], "cgroup2 ") {
+			return strings.Split(parts[0], " ")[common.MountPointIndex]
+		}
+	}
+	return ""
+}
+
+// OpenProcs 打开进程在各cgroup层级中所在cgroup的cgroup.procs。其它进程向其中写入0即可加入与该进程相同的cgroup，
+// 不需要能够访问宿主机上的cgroup路径。未挂载的层级会被跳过
+func OpenProcs(pid int) ([]*os.File, error) {
+	contentBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read cgroup of process %d", pid)
+	}
+
+	var files []*os.File
+	// 每行的格式为`hierarchy-ID:controller-list:cgroup-path`，cgroup v2的controller-list为空
+	for _, line := range strings.Split(strings.TrimSpace(string(contentBytes)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		var cgroupRoot string
+		if fields[1] == "" {
+			cgroupRoot = findCgroup2Mountpoint()
+		} else {
+			cgroupRoot = findCgroupMountpoint(strings.Split(fields[1], ",")[0])
+		}
+		if cgroupRoot == "" {
+			continue
+		}
+
+		procsPath := path.Join(cgroupRoot, fields[2], "cgroup.procs")
+		file, err := os.OpenFile(procsPath, os.O_WRONLY, 0)
+		if err != nil {
+			for _, opened := range files {
+				_ = opened.Close()
+			}
+			return nil, errors.Wrapf(err, "open %s", procsPath)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"path"
+	"time"
 
 	"github.com/liruonian/basin/common"
 
@@ -21,11 +23,137 @@ var initCommand = cli.Command{
 	},
 }
 
+var reaperCommand = cli.Command{
+	Name:            "reaper",
+	Usage:           "Init process which forwards signals and reaps orphans in container. Do not call it outside",
+	SkipFlagParsing: true,
+	Action: func(context *cli.Context) error {
+		return container.RunContainerReaper(context.Args())
+	},
+}
+
+var shimCommand = cli.Command{
+	Name:  "shim",
+	Usage: "Monitor process which owns and reaps a container. Do not call it outside",
+	Action: func(context *cli.Context) error {
+		return container.RunContainerMonitor()
+	},
+}
+
+// containerFlags run和create共用的容器参数
+var containerFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "i",
+		Usage: "Keep STDIN open even if not attached, for containers without tty",
+	},
+	cli.StringFlag{
+		Name:  "workdir, w",
+		Usage: "Working directory inside the container",
+	},
+	cli.StringFlag{
+		Name:  "user, u",
+		Usage: "Username or UID (format: <name|uid>[:<group|gid>])",
+	},
+	cli.StringFlag{
+		Name:  "hostname",
+		Usage: "Container host name, defaults to the container name",
+	},
+	cli.StringFlag{
+		Name:  "domainname",
+		Usage: "Container NIS domain name",
+	},
+	cli.StringSliceFlag{
+		Name:  "mask",
+		Usage: "Additional path to mask inside the container",
+	},
+	cli.StringSliceFlag{
+		Name:  "unmask",
+		Usage: "Default masked or read-only path to expose, ALL for all of them",
+	},
+	cli.StringSliceFlag{
+		Name:  "readonly-path",
+		Usage: "Additional path to mount read-only inside the container",
+	},
+	cli.BoolFlag{
+		Name:  "read-only",
+		Usage: "Mount the container's root filesystem as read only",
+	},
+	cli.BoolTFlag{
+		Name:  "read-only-tmpfs",
+		Usage: "Mount tmpfs on /tmp, /run and /var/tmp when --read-only is set",
+	},
+	cli.StringSliceFlag{
+		Name:  "cap-add",
+		Usage: "Add Linux capabilities, ALL for all of them",
+	},
+	cli.StringSliceFlag{
+		Name:  "cap-drop",
+		Usage: "Drop Linux capabilities, ALL for all of them",
+	},
+	cli.StringSliceFlag{
+		Name:  "security-opt",
+		Usage: "Security options (seccomp=<profile.json>|unconfined, no-new-privileges)",
+	},
+	cli.BoolFlag{
+		Name:  "privileged",
+		Usage: "Give extended privileges to this container",
+	},
+	cli.StringFlag{
+		Name:  "mem",
+		Usage: "Memory limit",
+	},
+	cli.StringFlag{
+		Name:  "cpu",
+		Usage: "Limit cpu cfs quota",
+	},
+	cli.StringFlag{
+		Name:  "cpuset",
+		Usage: "CPUs in which to allow execution",
+	},
+	cli.StringFlag{
+		Name:  "volume",
+		Usage: "Bind mount a volume as host:container, or create an anonymous volume with only the container path",
+	},
+	cli.StringFlag{
+		Name:  "name",
+		Usage: "Set container name",
+	},
+	cli.StringSliceFlag{
+		Name:  "env",
+		Usage: "Set environment variables",
+	},
+	cli.StringFlag{
+		Name:  "network",
+		Usage: "Connect a container to a network",
+	},
+	cli.StringSliceFlag{
+		Name:  "port",
+		Usage: "Expose a port or a range of ports",
+	},
+	cli.StringFlag{
+		Name:  "restart",
+		Usage: "Restart policy to apply when a container exits (no|on-failure[:max]|always|unless-stopped)",
+	},
+	cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "Signal to stop the container",
+		Value: "SIGTERM",
+	},
+	cli.StringSliceFlag{
+		Name:  "ulimit",
+		Usage: "Ulimit options (eg: nofile=1024:2048)",
+	},
+	cli.BoolFlag{
+		Name:  "init",
+		Usage: "Run an init inside the container that forwards signals and reaps processes",
+	},
+}
+
 // eg: basin run -it -name base base-1.0.0 /bin/bash
 var runCmd = cli.Command{
 	Name:  "run",
 	Usage: "Run a command in a new lightweight container",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{
 			Name:  "it",
 			Usage: "Enable tty",
@@ -35,72 +163,137 @@ var runCmd = cli.Command{
 			Usage: "Run container in background",
 		},
 		cli.StringFlag{
-			Name:  "mem",
-			Usage: "Memory limit",
-		},
-		cli.StringFlag{
-			Name:  "cpu",
-			Usage: "Limit cpu cfs quota",
-		},
-		cli.StringFlag{
-			Name:  "cpuset",
-			Usage: "CPUs in which to allow execution",
+			Name:  "detach-keys",
+			Usage: "Key sequence for detaching a container",
+			Value: container.DefaultDetachKeys,
 		},
-		cli.StringFlag{
-			Name:  "volume",
-			Usage: "Bind mount a volume",
-		},
-		cli.StringFlag{
-			Name:  "name",
-			Usage: "Set container name",
-		},
-		cli.StringSliceFlag{
-			Name:  "env",
-			Usage: "Set environment variables",
-		},
-		cli.StringFlag{
-			Name:  "network",
-			Usage: "Connect a container to a network",
-		},
-		cli.StringSliceFlag{
-			Name:  "port",
-			Usage: "Expose a port or a range of ports",
-		},
-	},
+	}, containerFlags...),
 	Action: func(context *cli.Context) error {
-		// 命令行参数预校验
-		if len(context.Args()) < 2 {
-			return errors.New("invalid parameters")
-		}
-		// tty&detach 不能同时出现
+		// 同时指定it和d时，容器保留标准输入，可以在之后通过attach连接
 		tty := context.Bool("it")
 		detach := context.Bool("d")
-		if tty && detach {
-			return errors.New("it and d parameter can not both provided")
-		}
-
-		params := &common.RunParam{
-			TTY:               tty,
-			ContainerName:     context.String("name"),
-			Envs:              context.StringSlice("env"),
-			Network:           context.String("network"),
-			PortMapping:       context.StringSlice("port"),
-			Volume:            context.String("volume"),
-			ImageName:         context.Args()[0],
-			ContainerCommands: context.Args()[1:],
-			CgroupConfig: &common.CgroupParam{
-				CpuCfsQuota: context.Int("cpu"),
-				CpuSet:      context.String("cpuset"),
-				MemoryLimit: context.String("mem"),
-			},
+
+		params, err := parseRunParam(context, tty, detach)
+		if err != nil {
+			return err
+		}
+		if _, err = container.ParseDetachKeys(context.String("detach-keys")); err != nil {
+			return err
 		}
 
-		container.Run(params)
+		container.Run(params, context.String("detach-keys"))
 
 		return nil
 	},
 }
 
+// eg: basin create -name base base-1.0.0 top -b
+var createCommand = cli.Command{
+	Name:  "create",
+	Usage: "Create a new container which can be started later",
+	Flags: containerFlags,
+	Action: func(context *cli.Context) error {
+		// 已创建的容器由监控进程持有，与后台运行的容器一致
+		params, err := parseRunParam(context, false, true)
+		if err != nil {
+			return err
+		}
+		return container.Create(params)
+	},
+}
+
+func parseRunParam(context *cli.Context, tty, detach bool) (*common.RunParam, error) {
+	// 命令行参数预校验
+	if len(context.Args()) < 2 {
+		return nil, errors.New("invalid parameters")
+	}
+	// 重启策略由后台容器的监控进程执行
+	restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+	if err != nil {
+		return nil, err
+	}
+	if restartPolicy.Name != common.RestartNo && !detach {
+		return nil, errors.New("restart policy can only be used with d parameter")
+	}
+	if _, err = container.ParseSignal(context.String("stop-signal")); err != nil {
+		return nil, err
+	}
+	for _, ulimit := range context.StringSlice("ulimit") {
+		if _, err = container.ParseUlimit(ulimit); err != nil {
+			return nil, err
+		}
+	}
+	if workdir := context.String("workdir"); workdir != "" && !path.IsAbs(workdir) {
+		return nil, fmt.Errorf("the working directory %s is invalid, it needs to be an absolute path", workdir)
+	}
+	// 特权容器保留全部capability，不屏蔽任何系统路径，未指定seccomp配置时不启用seccomp
+	privileged := context.Bool("privileged")
+	unmask, capAdd := context.StringSlice("unmask"), context.StringSlice("cap-add")
+	if privileged {
+		unmask = append(unmask, container.UnmaskAll)
+		capAdd = append(capAdd, container.CapabilityAll)
+	}
+	maskedPaths, readonlyPaths, err := container.ResolveSystemPaths(
+		context.StringSlice("mask"), unmask, context.StringSlice("readonly-path"))
+	if err != nil {
+		return nil, err
+	}
+	capabilities, err := container.ResolveCapabilities(capAdd, context.StringSlice("cap-drop"))
+	if err != nil {
+		return nil, err
+	}
+	seccompProfile, noNewPrivileges, err := container.ParseSecurityOpts(context.StringSlice("security-opt"))
+	if err != nil {
+		return nil, err
+	}
+	if privileged && seccompProfile == "" {
+		seccompProfile = container.SeccompUnconfined
+	}
+	for _, name := range []string{context.String("hostname"), context.String("domainname")} {
+		if name == "" {
+			continue
+		}
+		if err = container.ValidateHostname(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &common.RunParam{
+		TTY:               tty,
+		Interactive:       context.Bool("i"),
+		Detach:            detach,
+		ContainerName:     context.String("name"),
+		Envs:              context.StringSlice("env"),
+		Network:           context.String("network"),
+		PortMapping:       context.StringSlice("port"),
+		Volume:            context.String("volume"),
+		ImageName:         context.Args()[0],
+		ContainerCommands: context.Args()[1:],
+		CgroupConfig: &common.CgroupParam{
+			CpuCfsQuota: context.Int("cpu"),
+			CpuSet:      context.String("cpuset"),
+			MemoryLimit: context.String("mem"),
+		},
+		RestartPolicy:   restartPolicy,
+		StopSignal:      context.String("stop-signal"),
+		Ulimits:         context.StringSlice("ulimit"),
+		Init:            context.Bool("init"),
+		Workdir:         context.String("workdir"),
+		User:            context.String("user"),
+		Hostname:        context.String("hostname"),
+		Domainname:      context.String("domainname"),
+		MaskedPaths:     maskedPaths,
+		ReadonlyPaths:   readonlyPaths,
+		ReadOnly:        context.Bool("read-only"),
+		ReadOnlyTmpfs:   context.BoolT("read-only-tmpfs"),
+		Capabilities:    capabilities,
+		SecurityOpt:     context.StringSlice("security-opt"),
+		SeccompProfile:  seccompProfile,
+		NoNewPrivileges: noNewPrivileges,
+		Privileged:      privileged,
+	}, nil
+}
+
 var listCommand = cli.Command{
 	Name:  "ps",
 	Usage: "list all the containers",
@@ -125,30 +318,295 @@ var logCommand = cli.Command{
 
 var stopCommand = cli.Command{
 	Name:  "stop",
-	Usage: "stop a container",
+	Usage: "stop one or more containers",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Usage: "Seconds to wait for stop before killing it",
+			Value: int(container.DefaultStopTimeout / time.Second),
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
-		containerName := context.Args().Get(0)
-		container.Stop(containerName)
+		timeout := time.Duration(context.Int("t")) * time.Second
+		for _, containerName := range context.Args() {
+			if err := container.Stop(containerName, timeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var killCommand = cli.Command{
+	Name:  "kill",
+	Usage: "kill one or more running containers",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "s",
+			Usage: "Signal to send to the container",
+			Value: "SIGKILL",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			if err := container.Kill(containerName, context.String("s")); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var startCommand = cli.Command{
+	Name:  "start",
+	Usage: "start one or more created or stopped containers",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			if err := container.Start(containerName); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var pauseCommand = cli.Command{
+	Name:  "pause",
+	Usage: "pause all processes within one or more containers",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			if err := container.Pause(containerName); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:  "unpause",
+	Usage: "unpause all processes within one or more containers",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			if err := container.Unpause(containerName); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var restartCommand = cli.Command{
+	Name:  "restart",
+	Usage: "restart one or more containers",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Usage: "Seconds to wait for stop before killing it",
+			Value: int(container.DefaultStopTimeout / time.Second),
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		for _, containerName := range context.Args() {
+			if err := container.Restart(containerName, timeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// eg: basin wait base
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "block until one or more containers stop, then print their exit codes",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		// 以最后一个容器的退出码作为basin wait的退出码
+		code := 0
+		for _, containerName := range context.Args() {
+			var err error
+			if code, err = container.Wait(containerName); err != nil {
+				return err
+			}
+			fmt.Println(code)
+		}
+		if code != 0 {
+			return cli.NewExitError("", code)
+		}
 		return nil
 	},
 }
 
 var removeCommand = cli.Command{
 	Name:  "rm",
-	Usage: "remove unused containers",
+	Usage: "remove one or more containers",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "f",
+			Usage: "Force the removal of a running container",
+		},
+		cli.BoolFlag{
+			Name:  "v",
+			Usage: "Remove anonymous volumes associated with the container",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
-		containerName := context.Args().Get(0)
-		container.Remove(containerName)
+		for _, containerName := range context.Args() {
+			if err := container.Remove(containerName, context.Bool("f"), context.Bool("v")); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var containerCommand = cli.Command{
+	Name:  "container",
+	Usage: "container management commands",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove all stopped containers",
+			Action: func(context *cli.Context) error {
+				return container.Prune()
+			},
+		},
+	},
+}
+
+// eg: basin exec -it base /bin/sh
+var execCommand = cli.Command{
+	Name:  "exec",
+	Usage: "Run a command in a running container",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "it",
+			Usage: "Allocate a pseudo-TTY and keep STDIN open, STDIN is not forwarded without it",
+		},
+		cli.StringSliceFlag{
+			Name:  "e",
+			Usage: "Set environment variables",
+		},
+		cli.StringFlag{
+			Name:  "workdir, w",
+			Usage: "Working directory inside the container",
+		},
+		cli.StringFlag{
+			Name:  "user, u",
+			Usage: "Username or UID (format: <name|uid>[:<group|gid>])",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("missing container name or command")
+		}
+		if workdir := context.String("workdir"); workdir != "" && !path.IsAbs(workdir) {
+			return fmt.Errorf("the working directory %s is invalid, it needs to be an absolute path", workdir)
+		}
+		code, err := container.Exec(context.Args()[0], context.Args()[1:], context.StringSlice("e"), context.String("workdir"), context.String("user"), context.Bool("it"))
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return cli.NewExitError("", code)
+		}
 		return nil
 	},
 }
 
+// eg: basin inspect --format '{{.Spec.ImageName}}' base
+var inspectCommand = cli.Command{
+	Name:  "inspect",
+	Usage: "Display detailed information of a container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return container.Inspect(context.Args().Get(0), context.String("format"))
+	},
+}
+
+var topCommand = cli.Command{
+	Name:  "top",
+	Usage: "Display the running processes of a container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "o",
+			Usage: "Comma separated columns to display (pid,cpid,ppid,uid,user,stat,time,comm,cmd)",
+			Value: container.DefaultTopColumns,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return container.Top(context.Args().Get(0), context.String("o"))
+	},
+}
+
+var statsCommand = cli.Command{
+	Name:  "stats",
+	Usage: "Display a live stream of container resource usage statistics",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "Print the statistics once in JSON format",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return container.Stats(context.Args(), context.Bool("no-stream"))
+	},
+}
+
+var attachCommand = cli.Command{
+	Name:  "attach",
+	Usage: "Attach to the input and output of a running container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "detach-keys",
+			Usage: "Key sequence for detaching a container",
+			Value: container.DefaultDetachKeys,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return container.Attach(context.Args().Get(0), context.String("detach-keys"))
+	},
+}
+
 var networkCommand = cli.Command{
 	Name:  "network",
 	Usage: "container network commands",
@@ -9,4 +9,32 @@ type BaseConfig struct {
 	Volume      string   `json:"volume"`
 	PortMapping []string `json:"portmapping"`
 	CreatedTime string   `json:"createTime"`
+
+	// Spec 容器完整的运行参数
+	Spec *RunParam `json:"spec"`
+
+	// 以下为容器运行时信息
+	IPAddress    string  `json:"ipAddress"`
+	Veth         string  `json:"veth"`
+	PeerVeth     string  `json:"peerVeth"`
+	CgroupPath   string  `json:"cgroupPath"`
+	Mounts       []Mount `json:"mounts"`
+	MonitorPid   int     `json:"monitorPid"`
+	RestartCount int     `json:"restartCount"`
+	// ManuallyStopped 容器是否由basin stop停止，被手动停止的容器不会按照重启策略重启
+	ManuallyStopped bool   `json:"manuallyStopped"`
+	ExitCode        int    `json:"exitCode"`
+	ExitReason      string `json:"exitReason"`
+	FinishedTime    string `json:"finishedTime"`
+	// Error 容器进程初始化失败时的错误信息
+	Error string `json:"error"`
+	// AnonymousVolume 匿名卷在宿主机上的目录，basin rm -v时删除
+	AnonymousVolume string `json:"anonymousVolume"`
+}
+
+type Mount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Options     string `json:"options"`
 }
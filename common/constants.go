@@ -7,15 +7,32 @@ const (
 	// CgroupName Cgroup名
 	CgroupName = Basin + "-cgroup"
 
+	// Created 容器状态为已创建，等待启动
+	Created = "created"
 	// Running 容器状态为运行中
 	Running = "running"
 	// Stop 容器状态为结束
 	Stop = "stopped"
 	// Exit 容器状态为退出
 	Exit = "exited"
+	// Restarting 容器状态为等待重启
+	Restarting = "restarting"
+	// Paused 容器状态为已暂停
+	Paused = "paused"
 
-	// IdLength 容器ID的默认长度
-	IdLength = 10
+	// RestartNo 容器退出后不重启
+	RestartNo = "no"
+	// RestartOnFailure 容器以非0退出码退出时重启，可以限制最大重启次数
+	RestartOnFailure = "on-failure"
+	// RestartAlways 容器退出后总是重启
+	RestartAlways = "always"
+	// RestartUnlessStopped 除非被手动停止，否则容器退出后总是重启
+	RestartUnlessStopped = "unless-stopped"
+
+	// IdLength 容器ID的随机字节数，以16进制表示时长度为64
+	IdLength = 32
+	// ShortIdLength 容器短ID的长度，未指定容器名时以短ID作为容器名
+	ShortIdLength = 12
 
 	// ContainerDataUrl 容器数据主路径
 	ContainerDataUrl = "/var/run/" + Basin + "/"
@@ -23,8 +40,14 @@ const (
 	ContainerDataUrlFormat = ContainerDataUrl + "%s/"
 	// ConfigFileName 配置文件名
 	ConfigFileName = "config.json"
+	// ConfigLockFileName 读取-修改-写回配置文件时持有的锁文件
+	ConfigLockFileName = "config.lock"
 	// LogFileName 日志文件名
 	LogFileName = "container.log"
+	// StartFifoName 已创建的容器等待启动信号的命名管道
+	StartFifoName = "start.fifo"
+	// AttachSocketName 监控进程接受attach连接的unix socket
+	AttachSocketName = "attach.sock"
 
 	// RootUrl 根路径
 	RootUrl = "/root/"
@@ -36,6 +59,8 @@ const (
 	WorkDirFormat = RootUrl + "%s/work"
 	// MergedDirFormat merged层路径
 	MergedDirFormat = RootUrl + "%s/merged"
+	// VolumeUrl 匿名卷在宿主机上的主路径，匿名卷以容器ID命名
+	VolumeUrl = RootUrl + Basin + "-volumes/"
 	// OverlayFsFormat 拼接命令格式
 	OverlayFsFormat = "lowerdir=%s,upperdir=%s,workdir=%s"
 
@@ -49,4 +74,13 @@ const (
 	Perm0622 = 0622
 
 	MountPointIndex = 4
+
+	// EnvExecPid exec时用于传递目标容器PID的环境变量
+	EnvExecPid = Basin + "_pid"
+	// EnvExecCgroups exec时用于传递已打开的容器cgroup.procs数量的环境变量
+	EnvExecCgroups = Basin + "_cgroups"
+	// DefaultPathEnv 容器进程默认的PATH，不继承宿主机的环境变量
+	DefaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	// DefaultTermEnv 开启TTY时容器进程默认的TERM
+	DefaultTermEnv = "TERM=xterm"
 )
@@ -1,21 +1,45 @@
 package common
 
 type RunParam struct {
-	TTY               bool
-	ContainerName     string
-	Envs              []string
-	Network           string
-	PortMapping       []string
-	Volume            string
-	ImageName         string
-	CgroupConfig      *CgroupParam
-	ContainerCommands []string
+	TTY               bool           `json:"tty"`
+	Interactive       bool           `json:"interactive"`
+	Detach            bool           `json:"detach"`
+	ContainerName     string         `json:"containerName"`
+	Envs              []string       `json:"envs"`
+	Network           string         `json:"network"`
+	PortMapping       []string       `json:"portMapping"`
+	Volume            string         `json:"volume"`
+	ImageName         string         `json:"imageName"`
+	CgroupConfig      *CgroupParam   `json:"cgroupConfig"`
+	RestartPolicy     *RestartPolicy `json:"restartPolicy"`
+	StopSignal        string         `json:"stopSignal"`
+	Ulimits           []string       `json:"ulimits"`
+	Init              bool           `json:"init"`
+	Workdir           string         `json:"workdir"`
+	User              string         `json:"user"`
+	Hostname          string         `json:"hostname"`
+	Domainname        string         `json:"domainname"`
+	MaskedPaths       []string       `json:"maskedPaths"`
+	ReadonlyPaths     []string       `json:"readonlyPaths"`
+	ReadOnly          bool           `json:"readOnly"`
+	ReadOnlyTmpfs     bool           `json:"readOnlyTmpfs"`
+	Capabilities      []string       `json:"capabilities"`
+	SecurityOpt       []string       `json:"securityOpt"`
+	SeccompProfile    string         `json:"seccompProfile"`
+	NoNewPrivileges   bool           `json:"noNewPrivileges"`
+	Privileged        bool           `json:"privileged"`
+	ContainerCommands []string       `json:"containerCommands"`
 }
 
 type CgroupParam struct {
-	CpuCfsQuota int
-	CpuSet      string
+	CpuCfsQuota int    `json:"cpuCfsQuota"`
+	CpuSet      string `json:"cpuSet"`
 	// TODO ?
-	CpuShare    string
-	MemoryLimit string
+	CpuShare    string `json:"cpuShare"`
+	MemoryLimit string `json:"memoryLimit"`
+}
+
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"`
 }
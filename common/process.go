@@ -0,0 +1,36 @@
+package common
+
+// ProcessSpec 父进程通过管道发送给容器init进程的进程描述，init进程据此完成初始化并执行用户命令
+type ProcessSpec struct {
+	Args       []string `json:"args"`
+	Env        []string `json:"env"`
+	Cwd        string   `json:"cwd"`
+	User       string   `json:"user"`
+	Hostname   string   `json:"hostname"`
+	Domainname string   `json:"domainname"`
+	Rlimits    []Rlimit `json:"rlimits"`
+	// MaskedPaths 屏蔽的路径，ReadonlyPaths 重新挂载为只读的路径
+	MaskedPaths   []string `json:"maskedPaths"`
+	ReadonlyPaths []string `json:"readonlyPaths"`
+	// Readonly 为true时在初始化完成后将rootfs重新挂载为只读，Tmpfs 需要挂载tmpfs的路径
+	Readonly bool     `json:"readonly"`
+	Tmpfs    []string `json:"tmpfs"`
+	// Capabilities 用户命令保留的capability
+	Capabilities []string `json:"capabilities"`
+	// Seccomp 用户命令的seccomp配置，为空时不启用
+	Seccomp *Seccomp `json:"seccomp"`
+	// NoNewPrivileges 为true时设置no_new_privs，Privileged 为true时使用宿主机的全部设备且sysfs可写
+	NoNewPrivileges bool `json:"noNewPrivileges"`
+	Privileged      bool `json:"privileged"`
+	// Init 为true时init进程不执行用户命令，而是作为1号进程创建用户进程，并负责转发信号及回收僵尸进程
+	Init bool `json:"init"`
+	// Terminal 为true时init进程在容器内创建伪终端，作为用户命令的标准输入输出及控制终端
+	Terminal bool `json:"terminal"`
+}
+
+// Rlimit 容器进程的资源上限，Type为nofile、nproc等不带RLIMIT_前缀的小写名称
+type Rlimit struct {
+	Type string `json:"type"`
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
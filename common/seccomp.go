@@ -0,0 +1,44 @@
+package common
+
+// Seccomp docker/OCI格式的seccomp配置
+type Seccomp struct {
+	DefaultAction   string           `json:"defaultAction"`
+	DefaultErrnoRet *uint32          `json:"defaultErrnoRet,omitempty"`
+	Architectures   []string         `json:"architectures,omitempty"`
+	ArchMap         []SeccompArch    `json:"archMap,omitempty"`
+	Syscalls        []SeccompSyscall `json:"syscalls"`
+}
+
+// SeccompArch 主架构及其兼容的子架构
+type SeccompArch struct {
+	Arch      string   `json:"architecture"`
+	SubArches []string `json:"subArchitectures"`
+}
+
+// SeccompSyscall 对一组系统调用执行的动作，Args中的条件需全部满足。
+// Includes及Excludes按照容器的capability、架构及内核版本决定该规则是否生效
+type SeccompSyscall struct {
+	Name     string         `json:"name,omitempty"`
+	Names    []string       `json:"names,omitempty"`
+	Action   string         `json:"action"`
+	ErrnoRet *uint32        `json:"errnoRet,omitempty"`
+	Args     []SeccompArg   `json:"args,omitempty"`
+	Comment  string         `json:"comment,omitempty"`
+	Includes *SeccompFilter `json:"includes,omitempty"`
+	Excludes *SeccompFilter `json:"excludes,omitempty"`
+}
+
+// SeccompArg 系统调用参数的比较条件，SCMP_CMP_MASKED_EQ时Value为掩码，ValueTwo为期望值
+type SeccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+// SeccompFilter 规则生效的条件
+type SeccompFilter struct {
+	Caps      []string `json:"caps,omitempty"`
+	Arches    []string `json:"arches,omitempty"`
+	MinKernel string   `json:"minKernel,omitempty"`
+}
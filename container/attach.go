@@ -0,0 +1,248 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultDetachKeys 默认的detach按键序列
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// Attach 连接到运行中容器的标准输入输出，输入detach按键序列后断开连接，容器继续运行
+func Attach(containerName, detachKeys string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status != common.Running && containerInfo.Status != common.Paused {
+		return errors.Errorf("container %s is not running", containerName)
+	}
+
+	_, err = attachContainer(containerName, containerInfo.Spec.TTY, false, detachKeys, nil)
+	return err
+}
+
+// attachContainer 连接容器的attach socket，连接建立后执行onAttached；closeStdin为true时，本地输入结束后关闭容器的标准输入。
+// 容器退出时返回false，用户输入detach按键序列时返回true
+func attachContainer(containerName string, tty, closeStdin bool, detachKeys string, onAttached func() error) (bool, error) {
+	keys, err := ParseDetachKeys(detachKeys)
+	if err != nil {
+		return false, err
+	}
+
+	// 容器使用伪终端且本地为终端时，将本地终端设置为raw模式，并同步窗口大小
+	request := &attachRequest{Stdin: true, CloseStdin: closeStdin}
+	console := tty && isTerminal(os.Stdin.Fd())
+	if console {
+		if winsize, err := getWinsize(os.Stdin.Fd()); err == nil {
+			request.Rows, request.Cols = winsize.Row, winsize.Col
+		}
+	}
+
+	conn, err := dialAttachSocket(containerName, request)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if onAttached != nil {
+		if err = onAttached(); err != nil {
+			return false, err
+		}
+	}
+
+	if console {
+		restore, err := setRawTerminal(os.Stdin.Fd())
+		if err != nil {
+			return false, err
+		}
+		defer restore()
+
+		winchCh := make(chan os.Signal, 1)
+		signal.Notify(winchCh, syscall.SIGWINCH)
+		defer signal.Stop(winchCh)
+		go func() {
+			for range winchCh {
+				resizeContainer(containerName)
+			}
+		}()
+	} else if !tty {
+		// 将basin自身收到的信号转发给容器，与前台运行的进程行为一致；使用伪终端时由终端产生信号
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+		defer signal.Stop(signalCh)
+		go func() {
+			for sig := range signalCh {
+				if err := killContainer(containerName, sig.(syscall.Signal)); err != nil {
+					logrus.Warnf("forward signal %s to container %s failed: %v", sig, containerName, err)
+				}
+			}
+		}()
+	}
+
+	outputDone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(os.Stdout, conn)
+		close(outputDone)
+	}()
+	detachCh := make(chan struct{})
+	go func() {
+		if copyInput(conn, os.Stdin, keys) {
+			close(detachCh)
+			return
+		}
+		// 输入结束后关闭写端，继续接收容器输出直到容器退出
+		if unixConn, ok := conn.(*net.UnixConn); ok {
+			_ = unixConn.CloseWrite()
+		}
+	}()
+
+	select {
+	case <-outputDone:
+		return false, nil
+	case <-detachCh:
+		return true, nil
+	}
+}
+
+// dialAttachSocket 连接容器的attach socket并发送请求，非resize请求会等待监控进程登记客户端后的确认，
+// 之后容器的输出不会再丢失
+func dialAttachSocket(containerName string, request *attachRequest) (net.Conn, error) {
+	socketUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName) + common.AttachSocketName
+	conn, err := net.Dial("unix", socketUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect socket[%s] failed", socketUrl)
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "marshal attach request")
+	}
+	if _, err = conn.Write(append(requestBytes, '\n')); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "send attach request")
+	}
+	if request.Resize {
+		return conn, nil
+	}
+
+	ack := make([]byte, 1)
+	if _, err = io.ReadFull(conn, ack); err != nil || ack[0] != attachAck {
+		_ = conn.Close()
+		return nil, errors.Errorf("container %s did not acknowledge attach", containerName)
+	}
+	return conn, nil
+}
+
+// resizeContainer 将本地终端的窗口大小同步给容器的伪终端
+func resizeContainer(containerName string) {
+	winsize, err := getWinsize(os.Stdin.Fd())
+	if err != nil {
+		return
+	}
+	conn, err := dialAttachSocket(containerName, &attachRequest{Resize: true, Rows: winsize.Row, Cols: winsize.Col})
+	if err != nil {
+		logrus.Warnf("resize container %s failed: %v", containerName, err)
+		return
+	}
+	_ = conn.Close()
+}
+
+// copyInput 将输入转发给容器，遇到detach按键序列时返回true。
+// 与按键序列前缀相同的输入会暂存，确认不构成完整序列后再发送
+func copyInput(dst io.Writer, src io.Reader, keys []byte) bool {
+	buf := make([]byte, 1024)
+	var pending []byte
+	for {
+		n, err := src.Read(buf)
+		out := make([]byte, 0, n+len(pending))
+		for _, b := range buf[:n] {
+			if len(keys) > 0 && b == keys[len(pending)] {
+				pending = append(pending, b)
+				if len(pending) == len(keys) {
+					_, _ = dst.Write(out)
+					return true
+				}
+				continue
+			}
+			out = append(out, pending...)
+			pending = pending[:0]
+			if len(keys) > 0 && b == keys[0] {
+				pending = append(pending, b)
+				continue
+			}
+			out = append(out, b)
+		}
+		// 输入结束时暂存的内容不会再构成完整的按键序列，一并发送
+		if err != nil {
+			out = append(out, pending...)
+		}
+		if len(out) > 0 {
+			if _, werr := dst.Write(out); werr != nil {
+				return false
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// ParseDetachKeys 解析逗号分隔的detach按键序列，支持单个字符及ctrl-<key>，如ctrl-p,ctrl-q
+func ParseDetachKeys(detachKeys string) ([]byte, error) {
+	if detachKeys == "" {
+		return nil, nil
+	}
+	var keys []byte
+	for _, key := range strings.Split(detachKeys, ",") {
+		lower := strings.ToLower(key)
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case strings.HasPrefix(lower, "ctrl-") && len(lower) == len("ctrl-")+1:
+			c := lower[len(lower)-1]
+			switch {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c == '@':
+				keys = append(keys, 0)
+			case c >= '[' && c <= '_':
+				keys = append(keys, c-'['+27)
+			default:
+				return nil, errors.Errorf("invalid detach key %q", key)
+			}
+		default:
+			return nil, errors.Errorf("invalid detach key %q", key)
+		}
+	}
+	return keys, nil
+}
+
+// killContainer 向运行中容器的init进程发送信号
+func killContainer(containerName string, sig syscall.Signal) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return errors.Wrapf(err, "convert pid %s", containerInfo.Pid)
+	}
+	return syscall.Kill(pid, sig)
+}
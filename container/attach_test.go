@@ -0,0 +1,58 @@
+package container
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseDetachKeys(t *testing.T) {
+	tests := []struct {
+		keys    string
+		want    []byte
+		wantErr bool
+	}{
+		{keys: "", want: nil},
+		{keys: "ctrl-p,ctrl-q", want: []byte{16, 17}},
+		{keys: "CTRL-A,d", want: []byte{1, 'd'}},
+		{keys: "ctrl-@", want: []byte{0}},
+		{keys: "ctrl-[,ctrl-_", want: []byte{27, 31}},
+		{keys: "ctrl-1", wantErr: true},
+		{keys: "ctrl-pq", wantErr: true},
+		{keys: "ab", wantErr: true},
+		{keys: "ctrl-p,", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDetachKeys(tt.keys)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDetachKeys(%q) error = %v, wantErr %v", tt.keys, err, tt.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("ParseDetachKeys(%q) = %v, want %v", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestCopyInput(t *testing.T) {
+	keys := []byte{16, 17}
+	tests := []struct {
+		input    string
+		want     string
+		detached bool
+	}{
+		{input: "hello", want: "hello"},
+		{input: "ab\x10\x11cd", want: "ab", detached: true},
+		{input: "a\x10b", want: "a\x10b"},
+		{input: "a\x10\x10\x11", want: "a\x10", detached: true},
+		// 输入结束时暂存的按键序列前缀需要发送给容器
+		{input: "abc\x10", want: "abc\x10"},
+	}
+	for _, tt := range tests {
+		var dst bytes.Buffer
+		detached := copyInput(&dst, strings.NewReader(tt.input), keys)
+		if detached != tt.detached || dst.String() != tt.want {
+			t.Errorf("copyInput(%q) = %q, %v, want %q, %v", tt.input, dst.String(), detached, tt.want, tt.detached)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package container
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// CapabilityAll 表示全部capability，用于--cap-add ALL及--cap-drop ALL
+const CapabilityAll = "ALL"
+
+// capabilityNames 按编号排列的Linux capability名称，下标即为capability的编号
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// defaultCapabilities 容器默认保留的capability，在docker默认值的基础上去除了CAP_MKNOD：
+// basin没有设置devices cgroup，容器内的root持有CAP_MKNOD时可以创建宿主机磁盘等设备文件并直接读写
+var defaultCapabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
+// ResolveCapabilities 在默认capability的基础上移除drop中的、追加add中的capability，返回容器最终保留的capability。
+// 名称不区分大小写且可省略CAP_前缀，drop中包含ALL时先移除全部默认capability，add中包含ALL时保留全部capability
+func ResolveCapabilities(add, drop []string) ([]string, error) {
+	addAll, adds, err := normalizeCapabilities(add)
+	if err != nil {
+		return nil, err
+	}
+	dropAll, drops, err := normalizeCapabilities(drop)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool)
+	switch {
+	case addAll:
+		for _, name := range capabilityNames {
+			enabled[name] = true
+		}
+	case !dropAll:
+		for _, name := range defaultCapabilities {
+			enabled[name] = true
+		}
+	}
+	for _, name := range adds {
+		enabled[name] = true
+	}
+	for _, name := range drops {
+		delete(enabled, name)
+	}
+
+	// drop ALL后结果可能为空，返回非nil的切片，与未设置的旧容器区分
+	caps := make([]string, 0, len(enabled))
+	for _, name := range capabilityNames {
+		if enabled[name] {
+			caps = append(caps, name)
+		}
+	}
+	return caps, nil
+}
+
+// containerCapabilities 返回容器保留的capability，早期创建的容器未记录capability，使用默认值
+func containerCapabilities(param *common.RunParam) []string {
+	if param.Capabilities == nil {
+		return defaultCapabilities
+	}
+	return param.Capabilities
+}
+
+// configuredCapabilities 返回容器配置中保留的capability，未记录运行参数的容器返回默认值
+func configuredCapabilities(containerInfo *common.BaseConfig) []string {
+	if containerInfo.Spec == nil {
+		return defaultCapabilities
+	}
+	return containerCapabilities(containerInfo.Spec)
+}
+
+// effectiveCapabilities 读取容器1号进程的effective集合，容器未运行时返回nil
+func effectiveCapabilities(containerInfo *common.BaseConfig) ([]string, error) {
+	if containerInfo.Status != common.Running && containerInfo.Status != common.Paused {
+		return nil, nil
+	}
+	value, err := processStatus(containerInfo.Pid, "CapEff")
+	if err != nil {
+		return nil, err
+	}
+	mask, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse CapEff %s", value)
+	}
+	return capabilityNamesOf(mask), nil
+}
+
+// capabilityNamesOf 将位图转换为按编号排列的capability名称，忽略未知的capability
+func capabilityNamesOf(mask uint64) []string {
+	caps := make([]string, 0)
+	for i, name := range capabilityNames {
+		if mask&(1<<uint(i)) != 0 {
+			caps = append(caps, name)
+		}
+	}
+	return caps
+}
+
+// normalizeCapabilities 将capability名称统一为带CAP_前缀的大写形式，并返回其中是否包含ALL
+func normalizeCapabilities(names []string) (bool, []string, error) {
+	var (
+		all    bool
+		result []string
+	)
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == CapabilityAll {
+			all = true
+			continue
+		}
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		if capabilityIndex(name) < 0 {
+			return false, nil, errors.Errorf("unknown capability %q", name)
+		}
+		result = append(result, name)
+	}
+	return all, result, nil
+}
+
+// capabilityIndex 返回capability的编号，未知的capability返回-1
+func capabilityIndex(name string) int {
+	for i, capName := range capabilityNames {
+		if capName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// capabilityMask 将capability名称转换为位图，忽略当前内核不支持的capability
+func capabilityMask(caps []string) uint64 {
+	lastCap := lastCapability()
+	var mask uint64
+	for _, name := range caps {
+		if i := capabilityIndex(name); i >= 0 && i <= lastCap {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// lastCapability 返回当前内核支持的最大capability编号
+func lastCapability() int {
+	contentBytes, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err == nil {
+		if lastCap, err := strconv.Atoi(strings.TrimSpace(string(contentBytes))); err == nil {
+			return lastCap
+		}
+	}
+	return unix.CAP_LAST_CAP
+}
+
+// initCapabilityMask 读取容器1号进程的bounding集合，exec执行的命令与容器init进程保持相同的capability
+func initCapabilityMask() (uint64, error) {
+	value, err := initProcessStatus("CapBnd")
+	if err != nil {
+		return 0, err
+	}
+	mask, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse CapBnd %s", value)
+	}
+	return mask, nil
+}
+
+// limitCapabilities 将bounding及inheritable集合缩减为mask，并清空ambient集合，之后创建的进程无法再获得mask之外的capability。
+// 需在切换用户前调用，缩减bounding集合需要CAP_SETPCAP。capability只对当前线程生效，
+// 调用后当前goroutine固定在该线程上，由该线程切换用户并执行用户命令
+func limitCapabilities(mask uint64) error {
+	runtime.LockOSThread()
+
+	for i := 0; i <= lastCapability(); i++ {
+		if mask&(1<<uint(i)) != 0 {
+			continue
+		}
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(i), 0, 0, 0); err != nil {
+			return errors.Wrapf(err, "drop bounding capability %d", i)
+		}
+	}
+
+	header, data, err := getCapabilities()
+	if err != nil {
+		return err
+	}
+	data[0].Inheritable &= uint32(mask)
+	data[1].Inheritable &= uint32(mask >> 32)
+	if err = unix.Capset(header, &data[0]); err != nil {
+		return errors.Wrap(err, "set inheritable capabilities")
+	}
+
+	// 旧内核不支持ambient集合
+	if err = unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && err != syscall.EINVAL {
+		return errors.Wrap(err, "clear ambient capabilities")
+	}
+	return nil
+}
+
+// switchUser 切换运行用户，并将effective及permitted集合设置为mask与当前permitted集合的交集，需在limitCapabilities之后调用
+func switchUser(credential *syscall.Credential, mask uint64) error {
+	// 切换为非root用户时内核默认会清空permitted集合，保留后再按照mask设置
+	if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0); err != nil {
+		return errors.Wrap(err, "set keep capabilities")
+	}
+	if err := setUser(credential); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 0, 0, 0, 0); err != nil {
+		return errors.Wrap(err, "clear keep capabilities")
+	}
+
+	header, data, err := getCapabilities()
+	if err != nil {
+		return err
+	}
+	// 无法获得当前进程不具有的capability，basin本身运行在受限的环境中时，mask中可能包含permitted集合之外的capability
+	lo, hi := uint32(mask)&data[0].Permitted, uint32(mask>>32)&data[1].Permitted
+	if dropped := mask &^ (uint64(hi)<<32 | uint64(lo)); dropped != 0 {
+		logrus.Warnf("capabilities %s are not permitted to basin and will not take effect",
+			strings.Join(capabilityNamesOf(dropped), ","))
+	}
+	data[0].Effective, data[0].Permitted = lo, lo
+	data[1].Effective, data[1].Permitted = hi, hi
+	if err = unix.Capset(header, &data[0]); err != nil {
+		return errors.Wrap(err, "set capabilities")
+	}
+	return nil
+}
+
+// getCapabilities 获取当前线程的capability，64位的集合按照低32位及高32位分为两项
+func getCapabilities() (*unix.CapUserHeader, *[2]unix.CapUserData, error) {
+	header := &unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := new([2]unix.CapUserData)
+	if err := unix.Capget(header, &data[0]); err != nil {
+		return nil, nil, errors.Wrap(err, "get capabilities")
+	}
+	return header, data, nil
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     []string
+		drop    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: []string{"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_FOWNER", "CAP_FSETID", "CAP_KILL", "CAP_SETGID", "CAP_SETUID",
+				"CAP_SETPCAP", "CAP_NET_BIND_SERVICE", "CAP_NET_RAW", "CAP_SYS_CHROOT", "CAP_AUDIT_WRITE", "CAP_SETFCAP"},
+		},
+		{
+			name: "add and drop",
+			add:  []string{"sys_admin", "CAP_NET_ADMIN"},
+			drop: []string{"net_raw", " chown ", "CAP_SETFCAP"},
+			want: []string{"CAP_DAC_OVERRIDE", "CAP_FOWNER", "CAP_FSETID", "CAP_KILL", "CAP_SETGID", "CAP_SETUID",
+				"CAP_SETPCAP", "CAP_NET_BIND_SERVICE", "CAP_NET_ADMIN", "CAP_SYS_CHROOT", "CAP_SYS_ADMIN", "CAP_AUDIT_WRITE"},
+		},
+		{name: "drop all", drop: []string{"all"}, want: []string{}},
+		{name: "drop all then add", add: []string{"NET_BIND_SERVICE"}, drop: []string{"ALL"}, want: []string{"CAP_NET_BIND_SERVICE"}},
+		{name: "add all", add: []string{"ALL"}, want: capabilityNames},
+		{name: "add all then drop", add: []string{"ALL"}, drop: []string{"SYS_ADMIN"}, want: without(capabilityNames, "CAP_SYS_ADMIN")},
+		{name: "drop wins over add", add: []string{"SYS_ADMIN"}, drop: []string{"ALL", "SYS_ADMIN"}, want: []string{}},
+		{name: "unknown add", add: []string{"CAP_FOO"}, wantErr: true},
+		{name: "unknown drop", drop: []string{"foo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveCapabilities(tt.add, tt.drop)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ResolveCapabilities() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ResolveCapabilities() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCapabilityNamesOf(t *testing.T) {
+	tests := []struct {
+		mask uint64
+		want []string
+	}{
+		{mask: 0, want: []string{}},
+		{mask: 1<<0 | 1<<21, want: []string{"CAP_CHOWN", "CAP_SYS_ADMIN"}},
+		{mask: 1 << 40, want: []string{"CAP_CHECKPOINT_RESTORE"}},
+		{mask: 1 << 63, want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := capabilityNamesOf(tt.mask); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("capabilityNamesOf(%#x) = %v, want %v", tt.mask, got, tt.want)
+		}
+	}
+}
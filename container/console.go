@@ -0,0 +1,140 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// newConsole 通过/dev/ptmx创建一对伪终端，返回主设备及从设备。在容器内调用时使用容器自身的devpts实例
+func newConsole() (master *os.File, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open /dev/ptmx")
+	}
+
+	// 解锁从设备，并获取从设备的编号
+	if err = unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		_ = master.Close()
+		return nil, nil, errors.Wrap(err, "unlock pty")
+	}
+	ptn, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		_ = master.Close()
+		return nil, nil, errors.Wrap(err, "get pty number")
+	}
+
+	slavePath := fmt.Sprintf("/dev/pts/%d", ptn)
+	slave, err = os.OpenFile(slavePath, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, nil, errors.Wrapf(err, "open %s", slavePath)
+	}
+	return master, slave, nil
+}
+
+// newConsoleSocket 创建用于传递伪终端主设备的socket，child交给容器内的进程，由其创建伪终端后将主设备发回
+func newConsoleSocket() (parent *os.File, child *os.File, err error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new console socket")
+	}
+	return os.NewFile(uintptr(fds[0]), "console-parent"), os.NewFile(uintptr(fds[1]), "console-child"), nil
+}
+
+// setupConsole 在容器内创建伪终端并将主设备通过socket发回，从设备作为当前进程的标准输入输出及控制终端，
+// 返回从设备在容器内的路径。需已创建新的会话，指定credential时从设备归属于运行用户
+func setupConsole(socket *os.File, credential *syscall.Credential) (string, error) {
+	defer socket.Close()
+
+	master, slave, err := newConsole()
+	if err != nil {
+		return "", err
+	}
+	defer slave.Close()
+
+	if credential != nil {
+		if err = os.Chown(slave.Name(), int(credential.Uid), -1); err != nil {
+			_ = master.Close()
+			return "", errors.Wrapf(err, "chown %s", slave.Name())
+		}
+	}
+	err = unix.Sendmsg(int(socket.Fd()), []byte{0}, unix.UnixRights(int(master.Fd())), nil, 0)
+	_ = master.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "send console")
+	}
+
+	for fd := 0; fd <= 2; fd++ {
+		if err = unix.Dup3(int(slave.Fd()), fd, 0); err != nil {
+			return "", errors.Wrapf(err, "dup console to fd %d", fd)
+		}
+	}
+	if err = unix.IoctlSetInt(0, unix.TIOCSCTTY, 0); err != nil {
+		return "", errors.Wrap(err, "set controlling terminal")
+	}
+	return slave.Name(), nil
+}
+
+// receiveConsole 接收容器内的进程通过socket发回的伪终端主设备
+func receiveConsole(socket *os.File) (*os.File, error) {
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := unix.Recvmsg(int(socket.Fd()), buf, oob, unix.MSG_CMSG_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "receive console")
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) == 0 {
+		return nil, errors.New("receive console: no file descriptor")
+	}
+	fds, err := unix.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		return nil, errors.New("receive console: no file descriptor")
+	}
+	return os.NewFile(uintptr(fds[0]), "console"), nil
+}
+
+// isTerminal 判断文件描述符是否为终端
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	return err == nil
+}
+
+// setRawTerminal 将终端设置为raw模式，输入不再回显及按行缓冲，控制字符原样传递给容器。返回恢复原设置的函数
+func setRawTerminal(fd uintptr) (func(), error) {
+	termios, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	if err != nil {
+		return nil, errors.Wrap(err, "get terminal attributes")
+	}
+
+	// 与cfmakeraw保持一致
+	raw := *termios
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err = unix.IoctlSetTermios(int(fd), unix.TCSETS, &raw); err != nil {
+		return nil, errors.Wrap(err, "set terminal raw mode")
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(int(fd), unix.TCSETS, termios)
+	}, nil
+}
+
+// getWinsize 获取终端的窗口大小
+func getWinsize(fd uintptr) (*unix.Winsize, error) {
+	return unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+}
+
+// setWinsize 设置伪终端的窗口大小，内核会向终端的前台进程组发送SIGWINCH
+func setWinsize(fd uintptr, winsize *unix.Winsize) error {
+	return unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, winsize)
+}
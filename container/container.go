@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/liruonian/basin/common"
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 )
 
 func getContainerInfoByName(containerName string) (*common.BaseConfig, error) {
@@ -22,3 +26,101 @@ func getContainerInfoByName(containerName string) (*common.BaseConfig, error) {
 	}
 	return &containerInfo, nil
 }
+
+// lockContainerConfig 对容器的配置文件加排他锁，返回解锁函数。
+// basin命令与监控进程都会读取-修改-写回配置文件，需持有该锁以免相互覆盖对方的修改
+func lockContainerConfig(containerName string) (func(), error) {
+	lockFileUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName) + common.ConfigLockFileName
+	lockFile, err := os.OpenFile(lockFileUrl, os.O_RDWR|os.O_CREATE|unix.O_CLOEXEC, common.Perm0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open lock file %s", lockFileUrl)
+	}
+	for {
+		err = unix.Flock(int(lockFile.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		_ = lockFile.Close()
+		return nil, errors.Wrapf(err, "lock file %s", lockFileUrl)
+	}
+	// 关闭文件即释放锁
+	return func() { _ = lockFile.Close() }, nil
+}
+
+// updateContainerInfo 持有配置文件锁读取容器配置，经update修改后写回；update返回错误时不写回
+func updateContainerInfo(containerName string, update func(containerInfo *common.BaseConfig) error) (*common.BaseConfig, error) {
+	unlock, err := lockContainerConfig(containerName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if err = update(containerInfo); err != nil {
+		return nil, err
+	}
+	if err = recordConfig(containerInfo); err != nil {
+		return nil, errors.Wrapf(err, "record container %s config", containerName)
+	}
+	return containerInfo, nil
+}
+
+// shortId 返回容器ID的前12位，用于列表展示
+func shortId(containerId string) string {
+	if len(containerId) > common.ShortIdLength {
+		return containerId[:common.ShortIdLength]
+	}
+	return containerId
+}
+
+// containerNamePattern 容器名会作为数据目录名，只允许字母、数字及_.-
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func validateContainerName(containerName string) error {
+	// network目录用于保存网络配置
+	if !containerNamePattern.MatchString(containerName) || containerName == "network" {
+		return errors.Errorf("invalid container name %s", containerName)
+	}
+	return nil
+}
+
+// resolveContainerName 将完整ID、容器名或唯一的ID前缀解析为容器名
+func resolveContainerName(containerRef string) (string, error) {
+	if containerRef == "" {
+		return "", errors.New("missing container name")
+	}
+	containers, err := listContainerInfos()
+	if err != nil {
+		return "", err
+	}
+	for _, item := range containers {
+		if item.Id == containerRef {
+			return item.Name, nil
+		}
+	}
+	for _, item := range containers {
+		if item.Name == containerRef {
+			return item.Name, nil
+		}
+	}
+
+	var matched []string
+	for _, item := range containers {
+		if strings.HasPrefix(item.Id, containerRef) {
+			matched = append(matched, item.Name)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", errors.Errorf("no such container: %s", containerRef)
+	case 1:
+		return matched[0], nil
+	default:
+		return "", errors.Errorf("multiple containers match id prefix %s", containerRef)
+	}
+}
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// startTimeout 等待已创建的容器进入运行状态的超时时间
+const startTimeout = 10 * time.Second
+
+// Create 创建容器的workspace、容器进程及cgroup、network等资源，容器进程在收到启动信号前不会执行用户命令
+func Create(param *common.RunParam) error {
+	containerId, err := newContainerId(param)
+	if err != nil {
+		return err
+	}
+	if err = startMonitor(&monitorParam{Id: containerId, Param: param}); err != nil {
+		deleteContainerInfo(param.ContainerName)
+		return err
+	}
+	return nil
+}
+
+// openStartFifo 创建并打开用于接收启动信号的命名管道。以读写方式打开，既不会阻塞，
+// 又能保证basin start写入前管道始终有读端
+func openStartFifo(containerName string) (*os.File, error) {
+	containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
+	if err := os.MkdirAll(containerDataUrl, common.Perm0622); err != nil {
+		return nil, errors.Wrapf(err, "mkdir[%s] failed", containerDataUrl)
+	}
+
+	fifoUrl := containerDataUrl + common.StartFifoName
+	if err := os.Remove(fifoUrl); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "remove fifo[%s] failed", fifoUrl)
+	}
+	if err := syscall.Mkfifo(fifoUrl, common.Perm0622); err != nil {
+		return nil, errors.Wrapf(err, "mkfifo[%s] failed", fifoUrl)
+	}
+
+	fifo, err := os.OpenFile(fifoUrl, os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open fifo[%s] failed", fifoUrl)
+	}
+	return fifo, nil
+}
+
+// waitStartSignal 在后台阻塞读取命名管道，直到basin start写入启动信号
+func waitStartSignal(fifo *os.File) <-chan error {
+	startCh := make(chan error, 1)
+	go func() {
+		defer fifo.Close()
+
+		buf := make([]byte, 1)
+		if _, err := fifo.Read(buf); err != nil {
+			startCh <- errors.Wrapf(err, "read fifo[%s] failed", fifo.Name())
+			return
+		}
+		_ = os.Remove(fifo.Name())
+		startCh <- nil
+	}()
+	return startCh
+}
+
+// sendStartSignal 向已创建容器的监控进程发送启动信号，并等待容器进入运行状态
+func sendStartSignal(containerName string) error {
+	fifoUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName) + common.StartFifoName
+	// 以非阻塞方式打开，监控进程已退出时会立即返回错误
+	fifo, err := os.OpenFile(fifoUrl, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return errors.Wrapf(err, "open fifo[%s] failed", fifoUrl)
+	}
+	_, err = fifo.Write([]byte{0})
+	_ = fifo.Close()
+	if err != nil {
+		return errors.Wrapf(err, "write fifo[%s] failed", fifoUrl)
+	}
+
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		containerInfo, err := getContainerInfoByName(containerName)
+		if err != nil {
+			return errors.Wrapf(err, "get container %s info", containerName)
+		}
+		// 容器进程执行用户命令后即视为启动成功，即使随后很快退出
+		if containerInfo.Status != common.Created {
+			if containerInfo.Error != "" {
+				return errors.New(containerInfo.Error)
+			}
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return errors.Errorf("container %s did not start in %v", containerName, startTimeout)
+}
@@ -0,0 +1,150 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// device 容器/dev中创建的设备，Mode包含设备类型及权限
+type device struct {
+	Path  string
+	Major uint32
+	Minor uint32
+	Mode  uint32
+	Uid   int
+	Gid   int
+}
+
+// defaultDevices OCI规范中容器默认提供的设备
+var defaultDevices = []device{
+	{Path: "/dev/null", Major: 1, Minor: 3, Mode: syscall.S_IFCHR | 0666},
+	{Path: "/dev/zero", Major: 1, Minor: 5, Mode: syscall.S_IFCHR | 0666},
+	{Path: "/dev/full", Major: 1, Minor: 7, Mode: syscall.S_IFCHR | 0666},
+	{Path: "/dev/random", Major: 1, Minor: 8, Mode: syscall.S_IFCHR | 0666},
+	{Path: "/dev/urandom", Major: 1, Minor: 9, Mode: syscall.S_IFCHR | 0666},
+	{Path: "/dev/tty", Major: 5, Minor: 0, Mode: syscall.S_IFCHR | 0666},
+}
+
+// hostDevicesSkipped 特权容器不从宿主机复制的/dev子目录及设备，这些路径由容器自身挂载或创建
+var hostDevicesSkipped = map[string]bool{
+	"/dev/pts":     true,
+	"/dev/shm":     true,
+	"/dev/mqueue":  true,
+	"/dev/fd":      true,
+	"/dev/console": true,
+	"/dev/ptmx":    true,
+}
+
+// defaultDevSymlinks /dev中的标准符号链接，每项依次为链接路径及链接目标
+var defaultDevSymlinks = [][2]string{
+	{"/dev/fd", "/proc/self/fd"},
+	{"/dev/stdin", "/proc/self/fd/0"},
+	{"/dev/stdout", "/proc/self/fd/1"},
+	{"/dev/stderr", "/proc/self/fd/2"},
+	{"/dev/ptmx", "pts/ptmx"},
+}
+
+// setupDev 在已挂载tmpfs的/dev中创建默认设备、hostDevices中的宿主机设备、devpts、/dev/shm、/dev/mqueue及标准符号链接，
+// 需在pivotRoot并挂载/proc之后调用
+func setupDev(hostDevices []device) error {
+	// 设备权限以device中的Mode为准，不受umask影响
+	oldMask := syscall.Umask(0)
+	defer syscall.Umask(oldMask)
+
+	for _, dev := range defaultDevices {
+		if err := syscall.Mknod(dev.Path, dev.Mode, int(unix.Mkdev(dev.Major, dev.Minor))); err != nil {
+			return errors.Wrapf(err, "mknod %s", dev.Path)
+		}
+	}
+	for _, dev := range hostDevices {
+		if _, err := os.Lstat(dev.Path); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dev.Path), 0755); err != nil {
+			return errors.Wrapf(err, "mkdir %s", filepath.Dir(dev.Path))
+		}
+		if err := syscall.Mknod(dev.Path, dev.Mode, int(unix.Mkdev(dev.Major, dev.Minor))); err != nil {
+			return errors.Wrapf(err, "mknod %s", dev.Path)
+		}
+		if err := os.Lchown(dev.Path, dev.Uid, dev.Gid); err != nil {
+			return errors.Wrapf(err, "chown %s", dev.Path)
+		}
+	}
+
+	// 独立的devpts实例，容器内新建的伪终端与宿主机隔离
+	if err := os.MkdirAll("/dev/pts", 0755); err != nil {
+		return errors.Wrap(err, "mkdir /dev/pts")
+	}
+	if err := syscall.Mount("devpts", "/dev/pts", "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC,
+		"newinstance,ptmxmode=0666,mode=0620,gid=5"); err != nil {
+		return errors.Wrap(err, "mount devpts")
+	}
+
+	if err := os.MkdirAll("/dev/shm", 01777); err != nil {
+		return errors.Wrap(err, "mkdir /dev/shm")
+	}
+	if err := syscall.Mount("shm", "/dev/shm", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC,
+		"mode=1777,size=65536k"); err != nil {
+		return errors.Wrap(err, "mount /dev/shm")
+	}
+
+	// mqueue与容器的ipc命名空间绑定
+	if err := os.MkdirAll("/dev/mqueue", 0755); err != nil {
+		return errors.Wrap(err, "mkdir /dev/mqueue")
+	}
+	if err := syscall.Mount("mqueue", "/dev/mqueue", "mqueue", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return errors.Wrap(err, "mount /dev/mqueue")
+	}
+
+	for _, link := range defaultDevSymlinks {
+		if err := os.Symlink(link[1], link[0]); err != nil {
+			return errors.Wrapf(err, "symlink %s to %s", link[0], link[1])
+		}
+	}
+
+	return nil
+}
+
+// listHostDevices 列出宿主机/dev中的字符设备及块设备，供特权容器使用，需在pivotRoot之前调用
+func listHostDevices() ([]device, error) {
+	var devices []device
+	err := filepath.Walk("/dev", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 设备可能在遍历过程中被移除
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if hostDevicesSkipped[path] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&(os.ModeDevice|os.ModeCharDevice) == 0 {
+			return nil
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		devices = append(devices, device{
+			Path:  path,
+			Major: unix.Major(uint64(stat.Rdev)),
+			Minor: unix.Minor(uint64(stat.Rdev)),
+			Mode:  stat.Mode,
+			Uid:   int(stat.Uid),
+			Gid:   int(stat.Gid),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list host devices")
+	}
+	return devices, nil
+}
@@ -0,0 +1,333 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/liruonian/basin/cgroup/subsystem"
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// execConsoleFdIndex 开启TTY时exec的命令发回伪终端主设备的socket，seccomp过滤器使用fdIndex
+	execConsoleFdIndex = 4
+	// execCgroupFdIndex 容器各cgroup的cgroup.procs的起始描述符，数量由EnvExecCgroups传递
+	execCgroupFdIndex = 5
+)
+
+// Exec 在运行中的容器内执行命令，返回命令的退出码
+func Exec(containerName string, containerCommands, envs []string, workdir, user string, tty bool) (int, error) {
+	// 已由nsenter加入容器的命名空间，直接替换为用户命令，退出码由nsenter中的父进程转交
+	if os.Getenv(common.EnvExecPid) != "" {
+		return 0, execInContainer(containerCommands, workdir, user, tty)
+	}
+
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return 0, err
+	}
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status == common.Paused {
+		return 0, errors.Errorf("container %s is paused, unpause it before exec", containerName)
+	}
+	if containerInfo.Status != common.Running {
+		return 0, errors.Errorf("container %s is not running", containerName)
+	}
+
+	// 以容器init进程的环境变量为基础，再追加用户指定的环境变量
+	containerEnvs, err := getEnvsByPid(containerInfo.Pid)
+	if err != nil {
+		return 0, err
+	}
+	// 未指定时沿用容器的工作目录及运行用户；用户与容器不同时，HOME按照该用户的主目录重新设置
+	if workdir == "" {
+		workdir = containerInfo.Spec.Workdir
+	}
+	if user == "" {
+		user = containerInfo.Spec.User
+	} else if user != containerInfo.Spec.User {
+		containerEnvs = removeEnv(containerEnvs, "HOME")
+	}
+
+	args := []string{"exec"}
+	if tty {
+		args = append(args, "-it")
+	}
+	if workdir != "" {
+		args = append(args, "-w", workdir)
+	}
+	if user != "" {
+		args = append(args, "-u", user)
+	}
+	args = append(args, containerName)
+	args = append(args, containerCommands...)
+
+	// 与容器init进程使用相同的seccomp过滤器
+	filter, err := containerSeccompFilter(containerInfo.Spec)
+	if err != nil {
+		return 0, errors.Wrap(err, "compile seccomp profile")
+	}
+	filterPipe, err := newFilterPipe(filter)
+	if err != nil {
+		return 0, err
+	}
+	defer filterPipe.Close()
+
+	// 容器内看不到宿主机上的cgroup路径，由此处打开容器init进程所在cgroup的cgroup.procs后传递给exec的进程
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return 0, errors.Wrapf(err, "convert pid %s", containerInfo.Pid)
+	}
+	cgroupProcs, err := subsystem.OpenProcs(pid)
+	if err != nil {
+		return 0, errors.Wrapf(err, "open cgroups of container %s", containerName)
+	}
+	defer closeFiles(cgroupProcs)
+
+	// 重新执行当前程序，通过环境变量触发nsenter在Go运行时启动前加入容器的命名空间
+	cmd := exec.Command("/proc/self/exe", args...)
+	cmd.Env = append(containerEnvs, envs...)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", common.EnvExecPid, containerInfo.Pid))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", common.EnvExecCgroups, len(cgroupProcs)))
+	// 未开启TTY时伪终端socket的位置留空，cgroup.procs总是从execCgroupFdIndex开始
+	cmd.ExtraFiles = append([]*os.File{filterPipe, nil}, cgroupProcs...)
+	if tty {
+		return runWithConsole(cmd)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return exitCode(cmd.Run())
+}
+
+// runWithConsole 由容器内的进程在容器的devpts中创建伪终端并发回主设备，在本地终端与伪终端之间转发输入输出，
+// 返回命令的退出码
+func runWithConsole(cmd *exec.Cmd) (int, error) {
+	parent, child, err := newConsoleSocket()
+	if err != nil {
+		return 0, err
+	}
+	defer parent.Close()
+
+	cmd.ExtraFiles[execConsoleFdIndex-3] = child
+	// 创建伪终端之前的错误直接输出到本地
+	cmd.Stderr = os.Stderr
+	// 仅脱离当前会话，由容器内的进程获取控制终端
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	_ = child.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	master, err := receiveConsole(parent)
+	if err != nil {
+		// 容器内的进程在创建伪终端前已失败退出
+		return exitCode(cmd.Wait())
+	}
+	defer master.Close()
+
+	// 本地为终端时设置为raw模式，并同步窗口大小
+	if isTerminal(os.Stdin.Fd()) {
+		if winsize, err := getWinsize(os.Stdin.Fd()); err == nil {
+			_ = setWinsize(master.Fd(), winsize)
+		}
+		restore, err := setRawTerminal(os.Stdin.Fd())
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return 0, err
+		}
+		defer restore()
+
+		winchCh := make(chan os.Signal, 1)
+		signal.Notify(winchCh, syscall.SIGWINCH)
+		defer signal.Stop(winchCh)
+		go func() {
+			for range winchCh {
+				if winsize, err := getWinsize(os.Stdin.Fd()); err == nil {
+					_ = setWinsize(master.Fd(), winsize)
+				}
+			}
+		}()
+	}
+
+	// 从设备只由容器内的进程持有，其全部退出后读取主设备才会结束
+	go func() {
+		_, _ = io.Copy(master, os.Stdin)
+	}()
+	outputDone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(os.Stdout, master)
+		close(outputDone)
+	}()
+
+	code, err := exitCode(cmd.Wait())
+	<-outputDone
+	return code, err
+}
+
+func execInContainer(containerCommands []string, workdir, user string, tty bool) error {
+	// 执行用户命令前加入容器的cgroup，使exec的进程同样受到资源限制，并能被暂停、统计及停止
+	if err := joinContainerCgroups(); err != nil {
+		return err
+	}
+	// nsenter在加入pid命名空间后会再fork一次，因此在容器内的进程中创建会话并获取控制终端，
+	// 使前台进程组对容器内的进程可见
+	if tty {
+		if _, err := syscall.Setsid(); err != nil {
+			return errors.Wrap(err, "setsid")
+		}
+	}
+	if workdir != "" {
+		if err := os.Chdir(workdir); err != nil {
+			return errors.Wrapf(err, "chdir %s", workdir)
+		}
+	}
+
+	var envs []string
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, common.EnvExecPid+"=") && !strings.HasPrefix(env, common.EnvExecCgroups+"=") {
+			envs = append(envs, env)
+		}
+	}
+
+	// 加入mnt命名空间后根目录即为容器的根目录，以容器自身的passwd及group文件解析运行用户
+	credential, home, err := lookupUser(user)
+	if err != nil {
+		return err
+	}
+	if home != "" && !hasEnv(envs, "HOME") {
+		envs = append(envs, "HOME="+home)
+	}
+	// 已加入容器的mnt命名空间，伪终端创建在容器的devpts中，容器内可以通过/dev/pts访问
+	if tty {
+		if _, err = setupConsole(os.NewFile(uintptr(execConsoleFdIndex), "console"), credential); err != nil {
+			return errors.Wrap(err, "setup console")
+		}
+	}
+
+	path, err := exec.LookPath(containerCommands[0])
+	if err != nil {
+		return errors.Wrapf(err, "look path %s", containerCommands[0])
+	}
+	capMask, err := initCapabilityMask()
+	if err != nil {
+		return err
+	}
+	if err = limitCapabilities(capMask); err != nil {
+		return err
+	}
+	// 与容器init进程保持相同的no_new_privs
+	noNewPrivs, err := initProcessStatus("NoNewPrivs")
+	if err != nil {
+		return err
+	}
+	if noNewPrivs == "1" {
+		if err = setNoNewPrivileges(); err != nil {
+			return err
+		}
+	}
+	filter, err := readSeccompFilter()
+	if err != nil {
+		return err
+	}
+	if err = loadSeccomp(filter); err != nil {
+		return err
+	}
+	if err = switchUser(credential, capMask); err != nil {
+		return err
+	}
+
+	return syscall.Exec(path, containerCommands, envs)
+}
+
+// joinContainerCgroups 向exec命令传递的各cgroup.procs写入0，将当前进程加入容器的cgroup
+func joinContainerCgroups() error {
+	count, err := strconv.Atoi(os.Getenv(common.EnvExecCgroups))
+	if err != nil {
+		return errors.Wrapf(err, "parse %s", common.EnvExecCgroups)
+	}
+	for i := 0; i < count; i++ {
+		procs := os.NewFile(uintptr(execCgroupFdIndex+i), "cgroup.procs")
+		_, err = procs.WriteString("0")
+		_ = procs.Close()
+		if err != nil {
+			return errors.Wrap(err, "join container cgroup")
+		}
+	}
+	return nil
+}
+
+// newFilterPipe 将seccomp过滤器写入管道，返回读端，由容器内的进程通过fdIndex读取
+func newFilterPipe(filter []unix.SockFilter) (*os.File, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "create seccomp pipe")
+	}
+	// 过滤器可能超过管道的缓冲区，在容器内的进程读取时写入
+	go func() {
+		_ = json.NewEncoder(writer).Encode(filter)
+		_ = writer.Close()
+	}()
+	return reader, nil
+}
+
+// readSeccompFilter 读取exec命令传递的seccomp过滤器
+func readSeccompFilter() ([]unix.SockFilter, error) {
+	pipe := os.NewFile(uintptr(fdIndex), "pipe")
+	defer pipe.Close()
+
+	var filter []unix.SockFilter
+	if err := json.NewDecoder(pipe).Decode(&filter); err != nil {
+		return nil, errors.Wrap(err, "read seccomp filter")
+	}
+	return filter, nil
+}
+
+func getEnvsByPid(pid string) ([]string, error) {
+	environPath := fmt.Sprintf("/proc/%s/environ", pid)
+	contentBytes, err := ioutil.ReadFile(environPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read file %s", environPath)
+	}
+	// environ中的环境变量以'\0'分隔
+	return strings.Split(strings.TrimRight(string(contentBytes), "\u0000"), "\u0000"), nil
+}
+
+// exitCode 从命令的执行结果中解析退出码，被信号终止时按照shell的约定返回128+信号值
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+		return waitStatusCode(status), nil
+	}
+	return exitErr.ExitCode(), nil
+}
+
+// waitStatusCode 将退出状态转换为退出码，被信号杀死时为128加信号值
+func waitStatusCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
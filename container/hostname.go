@@ -0,0 +1,94 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// maxHostnameLength 内核允许的主机名最大长度
+const maxHostnameLength = 64
+
+// hostnamePattern 主机名及域名由点分隔的标签组成，标签以字母或数字开头和结尾
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+// ValidateHostname 校验--hostname及--domainname的取值
+func ValidateHostname(hostname string) error {
+	if len(hostname) > maxHostnameLength || !hostnamePattern.MatchString(hostname) {
+		return errors.Errorf("invalid hostname %q", hostname)
+	}
+	return nil
+}
+
+// containerHostname 返回容器的主机名，未指定时使用容器名，容器名不是合法的主机名时使用短ID
+func containerHostname(containerInfo *common.BaseConfig) string {
+	if containerInfo.Spec.Hostname != "" {
+		return containerInfo.Spec.Hostname
+	}
+	if ValidateHostname(containerInfo.Name) == nil {
+		return containerInfo.Name
+	}
+	return shortId(containerInfo.Id)
+}
+
+// writeHostsFiles 将主机名写入容器的/etc/hostname，并在/etc/hosts中添加主机名到容器IP地址的映射，
+// 未接入网络的容器映射到127.0.1.1
+func writeHostsFiles(containerInfo *common.BaseConfig) error {
+	hostname := containerHostname(containerInfo)
+	names := hostname
+	if domainname := containerInfo.Spec.Domainname; domainname != "" {
+		names = hostname + "." + domainname + " " + hostname
+	}
+	ip := containerInfo.IPAddress
+	if ip == "" {
+		ip = "127.0.1.1"
+	}
+
+	hosts := strings.Join([]string{
+		"127.0.0.1\tlocalhost",
+		"::1\tlocalhost ip6-localhost ip6-loopback",
+		"fe00::0\tip6-localnet",
+		"ff00::0\tip6-mcastprefix",
+		"ff02::1\tip6-allnodes",
+		"ff02::2\tip6-allrouters",
+		ip + "\t" + names,
+	}, "\n") + "\n"
+
+	etcUrl := filepath.Join(fmt.Sprintf(common.MergedDirFormat, containerInfo.Name), "etc")
+	if err := writeEtcFile(etcUrl, "hostname", hostname+"\n"); err != nil {
+		return err
+	}
+	return writeEtcFile(etcUrl, "hosts", hosts)
+}
+
+// writeEtcFile 在容器rootfs的etc目录中重新创建文件。文件由宿主机一侧写入，
+// 镜像中的etc目录或目标文件可能是符号链接，不跟随符号链接以免写到容器rootfs之外
+func writeEtcFile(etcUrl, name, content string) error {
+	if info, err := os.Lstat(etcUrl); err == nil {
+		if !info.IsDir() {
+			return errors.Errorf("%s is not a directory", etcUrl)
+		}
+	} else if err = os.MkdirAll(etcUrl, common.Perm0755); err != nil {
+		return errors.Wrapf(err, "mkdir[%s] failed", etcUrl)
+	}
+
+	fileUrl := filepath.Join(etcUrl, name)
+	if err := os.Remove(fileUrl); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove %s", fileUrl)
+	}
+	file, err := os.OpenFile(fileUrl, os.O_CREATE|os.O_EXCL|os.O_WRONLY|syscall.O_NOFOLLOW, common.Perm0644)
+	if err != nil {
+		return errors.Wrapf(err, "create %s", fileUrl)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		return errors.Wrapf(err, "write %s", fileUrl)
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 package container
 
 import (
-	"io/ioutil"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,50 +14,236 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const fdIndex = 3
+// readonlyTmpfsPaths 只读容器中默认挂载tmpfs的路径，供程序写入临时文件
+var readonlyTmpfsPaths = []string{"/tmp", "/run", "/var/tmp"}
+
+const (
+	// fdIndex 容器进程读取进程描述的管道
+	fdIndex = 3
+	// errFdIndex 容器进程回传初始化错误的管道
+	errFdIndex = 4
+	// consoleFdIndex 开启TTY时容器进程发回伪终端主设备的socket
+	consoleFdIndex = 5
+)
 
 func RunContainerInitProcess() error {
-	containerCommand := readContainerCommand()
-	if len(containerCommand) == 0 {
-		return errors.New("run container get user command error, containerCommand is nil")
+	// 执行用户命令后错误管道随之关闭，父进程读到EOF即视为容器进程初始化成功
+	syscall.CloseOnExec(errFdIndex)
+	errPipe := os.NewFile(uintptr(errFdIndex), "errpipe")
+
+	if err := initContainerProcess(errPipe); err != nil {
+		logrus.Errorf("init container process failed: %v", err)
+		_, _ = errPipe.WriteString(err.Error())
+		_ = errPipe.Close()
+		return err
 	}
+	return nil
+}
 
-	err := setupMount()
+func initContainerProcess(errPipe *os.File) error {
+	spec, err := readProcessSpec()
 	if err != nil {
-		logrus.Errorf("setup mount failed: %v", err)
 		return err
 	}
+	if len(spec.Args) == 0 {
+		return errors.New("container command is empty")
+	}
 
-	path, err := exec.LookPath(containerCommand[0])
+	if err = setupMount(spec); err != nil {
+		return errors.Wrap(err, "setup mount")
+	}
+	// 切换到容器的根目录后，以容器自身的passwd及group文件解析运行用户
+	credential, home, err := lookupUser(spec.User)
 	if err != nil {
-		logrus.Errorf("Exec loop path error %v", err)
 		return err
 	}
+	if home != "" && !hasEnv(spec.Env, "HOME") {
+		spec.Env = append(spec.Env, "HOME="+home)
+	}
+	if spec.Terminal {
+		if err = setupContainerConsole(credential); err != nil {
+			return err
+		}
+	}
+	if err = setupProcess(spec, credential); err != nil {
+		return err
+	}
+	// 工作目录可能需要创建，因此在设置进程之后再将rootfs重新挂载为只读
+	if spec.Readonly {
+		if err = syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return errors.Wrap(err, "remount rootfs read-only")
+		}
+	}
 
-	if err = syscall.Exec(path, containerCommand[0:], os.Environ()); err != nil {
-		logrus.Errorf("RunContainerInitProcess exec :" + err.Error())
+	path, err := exec.LookPath(spec.Args[0])
+	if err != nil {
+		return errors.Wrapf(err, "look path %s", spec.Args[0])
+	}
+
+	// 启用--init时先复制basin二进制，之后加载的seccomp过滤器可能禁止memfd_create
+	var reaper *os.File
+	if spec.Init {
+		if reaper, err = cloneReaperBinary(); err != nil {
+			return err
+		}
+	}
+
+	// 先缩减bounding集合，之后创建或执行的用户进程均无法获得其之外的capability
+	capMask := capabilityMask(spec.Capabilities)
+	if err = limitCapabilities(capMask); err != nil {
+		return err
+	}
+	if spec.NoNewPrivileges {
+		if err = setNoNewPrivileges(); err != nil {
+			return err
+		}
+	}
+	// 未设置no_new_privs时加载seccomp需要CAP_SYS_ADMIN，因此在切换用户前加载，其后仅切换用户并执行用户命令
+	filter, err := compileSeccomp(spec.Seccomp, spec.Capabilities)
+	if err != nil {
+		return errors.Wrap(err, "compile seccomp profile")
+	}
+	if err = loadSeccomp(filter); err != nil {
+		return err
+	}
+	if err = switchUser(credential, capMask); err != nil {
+		return err
+	}
+	// 启用--init时以basin reaper作为1号进程，由其创建并回收用户进程
+	if spec.Init {
+		return execReaper(reaper, path, spec)
+	}
+	if err = syscall.Exec(path, spec.Args, spec.Env); err != nil {
+		return errors.Wrapf(err, "exec %s", path)
 	}
 	return nil
 }
 
-func readContainerCommand() []string {
+// setupContainerConsole 使用容器自身devpts中的伪终端作为标准输入输出，并将其绑定挂载到/dev/console
+func setupContainerConsole(credential *syscall.Credential) error {
+	slavePath, err := setupConsole(os.NewFile(uintptr(consoleFdIndex), "console"), credential)
+	if err != nil {
+		return errors.Wrap(err, "setup console")
+	}
+	console, err := os.OpenFile("/dev/console", os.O_CREATE|os.O_RDONLY, common.Perm0644)
+	if err != nil {
+		return errors.Wrap(err, "create /dev/console")
+	}
+	_ = console.Close()
+	if err = syscall.Mount(slavePath, "/dev/console", "", syscall.MS_BIND, ""); err != nil {
+		return errors.Wrapf(err, "bind mount %s to /dev/console", slavePath)
+	}
+	return nil
+}
+
+func readProcessSpec() (*common.ProcessSpec, error) {
 	pipe := os.NewFile(uintptr(fdIndex), "pipe")
 	defer pipe.Close()
-	msg, err := ioutil.ReadAll(pipe)
-	if err != nil {
-		logrus.Errorf("init read pipe error %v", err)
+
+	spec := new(common.ProcessSpec)
+	if err := json.NewDecoder(pipe).Decode(spec); err != nil {
+		return nil, errors.Wrap(err, "read process spec")
+	}
+	return spec, nil
+}
+
+// setupProcess 按照进程描述设置主机名、域名、资源上限、环境变量及工作目录
+func setupProcess(spec *common.ProcessSpec, credential *syscall.Credential) error {
+	if spec.Hostname != "" {
+		if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+			return errors.Wrapf(err, "set hostname %s", spec.Hostname)
+		}
+	}
+	if spec.Domainname != "" {
+		if err := syscall.Setdomainname([]byte(spec.Domainname)); err != nil {
+			return errors.Wrapf(err, "set domainname %s", spec.Domainname)
+		}
+	}
+	if err := setRlimits(spec.Rlimits); err != nil {
+		return err
+	}
+
+	// LookPath依赖当前进程的PATH，需与用户命令的环境变量保持一致
+	os.Clearenv()
+	for _, env := range spec.Env {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) == 2 {
+			_ = os.Setenv(kv[0], kv[1])
+		}
+	}
+
+	// 与docker一致，工作目录不存在时自动创建，并归属于运行用户
+	if spec.Cwd != "" {
+		if err := mkdirAllAs(spec.Cwd, credential); err != nil {
+			return err
+		}
+		if err := os.Chdir(spec.Cwd); err != nil {
+			return errors.Wrapf(err, "chdir %s", spec.Cwd)
+		}
+	}
+	return nil
+}
+
+// mkdirAllAs 创建目录及其不存在的上级目录，并将新创建的目录修改为运行用户所有，已存在的目录保持不变
+func mkdirAllAs(dir string, credential *syscall.Credential) error {
+	var created []string
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil || !os.IsNotExist(err) {
+			break
+		}
+		created = append(created, current)
+	}
+	if err := os.MkdirAll(dir, common.Perm0755); err != nil {
+		return errors.Wrapf(err, "mkdir %s", dir)
+	}
+	if credential == nil {
 		return nil
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	for _, createdDir := range created {
+		if err := os.Chown(createdDir, int(credential.Uid), int(credential.Gid)); err != nil {
+			return errors.Wrapf(err, "chown %s", createdDir)
+		}
+	}
+	return nil
 }
 
-func setupMount() error {
+// setUser 切换当前进程的运行用户
+func setUser(credential *syscall.Credential) error {
+	if credential == nil {
+		return nil
+	}
+
+	groups := make([]int, 0, len(credential.Groups))
+	for _, group := range credential.Groups {
+		groups = append(groups, int(group))
+	}
+	// 需先切换组，切换用户后将不再有权限修改
+	if err := syscall.Setgroups(groups); err != nil {
+		return errors.Wrap(err, "set groups")
+	}
+	if err := syscall.Setgid(int(credential.Gid)); err != nil {
+		return errors.Wrapf(err, "set gid %d", credential.Gid)
+	}
+	if err := syscall.Setuid(int(credential.Uid)); err != nil {
+		return errors.Wrapf(err, "set uid %d", credential.Uid)
+	}
+	return nil
+}
+
+func setupMount(spec *common.ProcessSpec) error {
 	pwd, err := os.Getwd()
 	if err != nil {
 		return errors.Wrapf(err, "get current location failed")
 	}
 
+	// 特权容器使用宿主机的全部设备，pivotRoot后宿主机的/dev不再可见，因此提前获取
+	var hostDevices []device
+	if spec.Privileged {
+		if hostDevices, err = listHostDevices(); err != nil {
+			return err
+		}
+	}
+
 	if err = pivotRoot(pwd); err != nil {
 		return errors.Wrapf(err, "pivot root failed")
 	}
@@ -71,6 +257,20 @@ func setupMount() error {
 	if err != nil {
 		return errors.Wrapf(err, "mount tmpfs failed")
 	}
+	if err = setupDev(hostDevices); err != nil {
+		return errors.Wrap(err, "setup dev")
+	}
+	if err = setupSystemPaths(spec.MaskedPaths, spec.ReadonlyPaths, spec.Privileged); err != nil {
+		return errors.Wrap(err, "setup system paths")
+	}
+	for _, tmpfs := range spec.Tmpfs {
+		if err = os.MkdirAll(tmpfs, common.Perm0755); err != nil {
+			return errors.Wrapf(err, "mkdir %s", tmpfs)
+		}
+		if err = syscall.Mount("tmpfs", tmpfs, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return errors.Wrapf(err, "mount tmpfs on %s", tmpfs)
+		}
+	}
 
 	return nil
 }
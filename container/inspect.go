@@ -0,0 +1,63 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// inspectInfo 在容器配置的基础上附加容器保留的capability，运行中的容器还附加其1号进程实际生效的capability
+type inspectInfo struct {
+	*common.BaseConfig
+	Capabilities          []string `json:"capabilities"`
+	EffectiveCapabilities []string `json:"effectiveCapabilities,omitempty"`
+}
+
+// Inspect 打印容器的完整配置，指定format时按照Go模板格式化输出
+func Inspect(containerName, format string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	effective, err := effectiveCapabilities(containerInfo)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s capabilities", containerName)
+	}
+	info := &inspectInfo{
+		BaseConfig:            containerInfo,
+		Capabilities:          configuredCapabilities(containerInfo),
+		EffectiveCapabilities: effective,
+	}
+
+	if format == "" {
+		contentBytes, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return errors.Wrapf(err, "json marshal %s", containerName)
+		}
+		_, err = fmt.Fprintln(os.Stdout, string(contentBytes))
+		return err
+	}
+
+	tmpl, err := template.New("inspect").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			contentBytes, err := json.Marshal(v)
+			return string(contentBytes), err
+		},
+	}).Parse(format)
+	if err != nil {
+		return errors.Wrapf(err, "parse format %s", format)
+	}
+	if err = tmpl.Execute(os.Stdout, info); err != nil {
+		return errors.Wrapf(err, "execute format %s", format)
+	}
+	_, err = fmt.Fprintln(os.Stdout)
+	return err
+}
@@ -0,0 +1,285 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// attachWriteTimeout 向attach客户端写入输出的超时时间，超时的客户端会被断开，避免阻塞容器输出
+const attachWriteTimeout = 5 * time.Second
+
+// attachAck 监控进程登记attach客户端后回复的确认字节，此后的容器输出都会发送给该客户端
+const attachAck = 0
+
+// attachRequest attach客户端连接后发送的第一行JSON，其后为客户端的标准输入
+type attachRequest struct {
+	// Stdin 是否将客户端的输入转发给容器
+	Stdin bool `json:"stdin"`
+	// CloseStdin 客户端输入结束后关闭未开启TTY的容器的标准输入，前台运行的容器由此读到EOF
+	CloseStdin bool `json:"closeStdin,omitempty"`
+	// Resize 为true时仅调整伪终端的窗口大小，处理后即关闭连接
+	Resize bool `json:"resize,omitempty"`
+	// Rows&Cols 客户端终端的窗口大小，为0时忽略
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+}
+
+// ioHub 由监控进程持有容器的标准输入输出：容器输出写入日志文件并广播给attach的客户端，
+// 客户端的输入写入容器的标准输入
+type ioHub struct {
+	mu       sync.Mutex
+	logFile  *os.File
+	listener net.Listener
+	// input 容器标准输入的写端，开启TTY时为伪终端的主设备，未开启TTY时为-i保留的标准输入管道，否则为nil
+	input *os.File
+	// console 伪终端的主设备，容器未开启TTY时为nil
+	console *os.File
+	// winsize 客户端最近一次设置的窗口大小，容器重启后应用到新的伪终端
+	winsize *unix.Winsize
+	clients map[net.Conn]struct{}
+}
+
+// newIOHub 打开容器日志文件，并监听attach使用的unix socket
+func newIOHub(containerName string) (*ioHub, error) {
+	containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
+	if err := os.MkdirAll(containerDataUrl, common.Perm0622); err != nil {
+		return nil, errors.Wrapf(err, "mkdir[%s] failed", containerDataUrl)
+	}
+
+	// 容器重启后继续追加写入原日志文件
+	logFileUrl := containerDataUrl + common.LogFileName
+	logFile, err := os.OpenFile(logFileUrl, os.O_CREATE|os.O_WRONLY|os.O_APPEND, common.Perm0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open file[%s] failed", logFileUrl)
+	}
+
+	socketUrl := containerDataUrl + common.AttachSocketName
+	if err = os.Remove(socketUrl); err != nil && !os.IsNotExist(err) {
+		_ = logFile.Close()
+		return nil, errors.Wrapf(err, "remove socket[%s] failed", socketUrl)
+	}
+	listener, err := net.Listen("unix", socketUrl)
+	if err != nil {
+		_ = logFile.Close()
+		return nil, errors.Wrapf(err, "listen socket[%s] failed", socketUrl)
+	}
+
+	hub := &ioHub{
+		logFile:  logFile,
+		listener: listener,
+		clients:  make(map[net.Conn]struct{}),
+	}
+	go hub.accept()
+	return hub, nil
+}
+
+// newProcessIO 为新的容器进程创建标准输入输出，返回交给容器进程的一端。
+// 开启TTY时伪终端由容器进程在容器内创建，此处只返回用于接收主设备的socket，容器进程的标准输入输出在此之前为空；
+// 否则标准输出与标准错误共用同一个管道，指定interactive时通过管道保留标准输入
+func (h *ioHub) newProcessIO(tty, interactive bool) (stdin *os.File, output *os.File, consoleSocket *os.File, err error) {
+	if tty {
+		parent, child, err := newConsoleSocket()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		go h.acceptConsole(parent)
+		return nil, nil, child, nil
+	}
+
+	var input *os.File
+	if interactive {
+		if stdin, input, err = os.Pipe(); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "new input pipe error")
+		}
+	}
+	outputReader, output, err := os.Pipe()
+	if err != nil {
+		if input != nil {
+			_ = stdin.Close()
+			_ = input.Close()
+		}
+		return nil, nil, nil, errors.Wrap(err, "new output pipe error")
+	}
+
+	h.mu.Lock()
+	h.input = input
+	h.mu.Unlock()
+	go h.copyOutput(outputReader, input)
+	return stdin, output, nil, nil
+}
+
+// acceptConsole 接收容器进程发回的伪终端主设备，作为容器的输入输出。容器进程在创建伪终端前退出时直接返回
+func (h *ioHub) acceptConsole(socket *os.File) {
+	master, err := receiveConsole(socket)
+	_ = socket.Close()
+	if err != nil {
+		logrus.Debugf("accept console: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	h.input = master
+	h.console = master
+	if h.winsize != nil {
+		_ = setWinsize(master.Fd(), h.winsize)
+	}
+	h.mu.Unlock()
+
+	h.copyOutput(master, master)
+}
+
+// copyOutput 将容器输出写入日志并广播给客户端，容器进程退出后断开全部客户端。
+// 伪终端的从设备全部关闭后，读取主设备会返回EIO，同样视为输出结束
+func (h *ioHub) copyOutput(outputReader *os.File, input *os.File) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := outputReader.Read(buf)
+		if n > 0 {
+			h.broadcast(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	_ = outputReader.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if input != nil {
+		_ = input.Close()
+		if h.input == input {
+			h.input = nil
+		}
+		if h.console == input {
+			h.console = nil
+		}
+	}
+	for conn := range h.clients {
+		_ = conn.Close()
+		delete(h.clients, conn)
+	}
+}
+
+func (h *ioHub) broadcast(data []byte) {
+	if _, err := h.logFile.Write(data); err != nil {
+		logrus.Errorf("write container log error %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for conn := range h.clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout))
+		if _, err := conn.Write(data); err != nil {
+			_ = conn.Close()
+			delete(h.clients, conn)
+		}
+	}
+}
+
+func (h *ioHub) accept() {
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			return
+		}
+		go h.serve(conn)
+	}
+}
+
+// serve 读取客户端的请求，并将其后续输入转发给容器的标准输入
+func (h *ioHub) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	request := new(attachRequest)
+	if err = json.Unmarshal(line, request); err != nil {
+		logrus.Warnf("invalid attach request: %v", err)
+		_ = conn.Close()
+		return
+	}
+
+	if request.Rows > 0 && request.Cols > 0 {
+		h.resize(&unix.Winsize{Row: request.Rows, Col: request.Cols})
+	}
+	if request.Resize {
+		_ = conn.Close()
+		return
+	}
+
+	// 登记与确认在同一把锁内完成，确认字节总是先于任何容器输出到达客户端
+	h.mu.Lock()
+	h.clients[conn] = struct{}{}
+	_ = conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout))
+	if _, err = conn.Write([]byte{attachAck}); err != nil {
+		_ = conn.Close()
+		delete(h.clients, conn)
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 && request.Stdin {
+			h.mu.Lock()
+			input := h.input
+			h.mu.Unlock()
+			if input != nil {
+				_, _ = input.Write(buf[:n])
+			}
+		}
+		// 客户端关闭写端后仍可继续接收输出，直到容器退出或客户端断开
+		if err == io.EOF {
+			if request.Stdin && request.CloseStdin {
+				h.closeInput()
+			}
+			return
+		}
+		if err != nil {
+			h.mu.Lock()
+			if _, ok := h.clients[conn]; ok {
+				_ = conn.Close()
+				delete(h.clients, conn)
+			}
+			h.mu.Unlock()
+			return
+		}
+	}
+}
+
+// closeInput 关闭未开启TTY的容器的标准输入管道，容器随后读到EOF
+func (h *ioHub) closeInput() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.input != nil && h.input != h.console {
+		_ = h.input.Close()
+		h.input = nil
+	}
+}
+
+// resize 调整伪终端的窗口大小，并记录下来供容器重启后使用
+func (h *ioHub) resize(winsize *unix.Winsize) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.winsize = winsize
+	if h.console != nil {
+		if err := setWinsize(h.console.Fd(), winsize); err != nil {
+			logrus.Warnf("resize console error %v", err)
+		}
+	}
+}
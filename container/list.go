@@ -8,39 +8,29 @@ import (
 	"text/tabwriter"
 
 	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 func ListContainers() {
-	files, err := ioutil.ReadDir(common.ContainerDataUrl)
+	containers, err := listContainerInfos()
 	if err != nil {
-		logrus.Errorf("read dir %s error %v", common.ContainerDataUrl, err)
+		logrus.Errorf("list containers error %v", err)
 		return
 	}
-	containers := make([]*common.BaseConfig, 0, len(files))
-	for _, file := range files {
-		if file.Name() == "network" {
-			continue
-		}
-		tmpContainer, err := getContainerInfo(file)
-		if err != nil {
-			logrus.Errorf("get container info error %v", err)
-			continue
-		}
-		containers = append(containers, tmpContainer)
-	}
 
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	_, err = fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
+	_, err = fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tRESTARTS\tCOMMAND\tCREATED\n")
 	if err != nil {
 		logrus.Errorf("Fprint error %v", err)
 	}
 	for _, item := range containers {
-		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			shortId(item.Id),
 			item.Name,
 			item.Pid,
-			item.Status,
+			displayStatus(item),
+			item.RestartCount,
 			item.Command,
 			item.CreatedTime)
 		if err != nil {
@@ -52,6 +42,27 @@ func ListContainers() {
 	}
 }
 
+// listContainerInfos 读取全部容器的配置信息，忽略无法解析的容器
+func listContainerInfos() ([]*common.BaseConfig, error) {
+	files, err := ioutil.ReadDir(common.ContainerDataUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir %s", common.ContainerDataUrl)
+	}
+	containers := make([]*common.BaseConfig, 0, len(files))
+	for _, file := range files {
+		if file.Name() == "network" {
+			continue
+		}
+		tmpContainer, err := getContainerInfo(file)
+		if err != nil {
+			logrus.Errorf("get container info error %v", err)
+			continue
+		}
+		containers = append(containers, tmpContainer)
+	}
+	return containers, nil
+}
+
 func getContainerInfo(file os.FileInfo) (*common.BaseConfig, error) {
 	containerName := file.Name()
 	configFileDir := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
@@ -70,3 +81,11 @@ func getContainerInfo(file os.FileInfo) (*common.BaseConfig, error) {
 
 	return info, nil
 }
+
+// displayStatus 返回ps中展示的容器状态，已退出的容器附带退出码
+func displayStatus(info *common.BaseConfig) string {
+	if info.Status == common.Exit || (info.Status == common.Stop && info.FinishedTime != "") {
+		return fmt.Sprintf("%s (%d)", info.Status, info.ExitCode)
+	}
+	return info.Status
+}
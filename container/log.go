@@ -10,6 +10,11 @@ import (
 )
 
 func ReadContainerLog(containerName string) {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		logrus.Errorf("Log container error %v", err)
+		return
+	}
 	logFileLocation := fmt.Sprintf(common.ContainerDataUrlFormat, containerName) + common.LogFileName
 	file, err := os.Open(logFileLocation)
 	defer file.Close()
@@ -0,0 +1,312 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// monitorParamFdIndex 监控进程读取启动参数的管道
+	monitorParamFdIndex = 3
+	// monitorStatusFdIndex 监控进程回传启动结果的管道
+	monitorStatusFdIndex = 4
+)
+
+// errStoppedBeforeStart 已创建的容器在收到启动信号后、运行用户命令前被basin stop停止
+var errStoppedBeforeStart = errors.New("container was stopped before start")
+
+type monitorParam struct {
+	// 新建容器时指定容器id及运行参数
+	Id    string           `json:"id"`
+	Param *common.RunParam `json:"param"`
+	// 启动已有容器时指定容器名
+	ContainerName string `json:"containerName"`
+}
+
+// startMonitor 启动独立的监控进程，由其创建并持有容器进程，待容器启动完成后返回
+func startMonitor(param *monitorParam) error {
+	paramReadPipe, paramWritePipe, err := os.Pipe()
+	if err != nil {
+		return errors.Wrap(err, "new param pipe error")
+	}
+	statusReadPipe, statusWritePipe, err := os.Pipe()
+	if err != nil {
+		return errors.Wrap(err, "new status pipe error")
+	}
+
+	initCmd, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		return errors.Wrap(err, "readLink /proc/self/exe failed")
+	}
+
+	// 监控进程脱离当前会话，basin run退出后继续负责回收容器进程
+	monitorCmd := exec.Command(initCmd, "shim")
+	monitorCmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	monitorCmd.ExtraFiles = []*os.File{paramReadPipe, statusWritePipe}
+	if err = monitorCmd.Start(); err != nil {
+		return errors.Wrap(err, "monitor start")
+	}
+	_ = paramReadPipe.Close()
+	_ = statusWritePipe.Close()
+
+	err = json.NewEncoder(paramWritePipe).Encode(param)
+	_ = paramWritePipe.Close()
+	if err != nil {
+		return errors.Wrap(err, "send monitor param")
+	}
+
+	// 监控进程启动容器成功后会直接关闭状态管道，否则写入错误信息
+	msg, err := ioutil.ReadAll(statusReadPipe)
+	_ = statusReadPipe.Close()
+	if err != nil {
+		return errors.Wrap(err, "read monitor status")
+	}
+	if len(msg) > 0 {
+		return errors.New(string(msg))
+	}
+
+	return monitorCmd.Process.Release()
+}
+
+// RunContainerMonitor 监控进程的入口，启动容器后等待其退出，并记录退出码、结束时间及原因
+func RunContainerMonitor() error {
+	// 避免管道被容器进程继承，否则basin run无法感知状态管道的关闭
+	syscall.CloseOnExec(monitorParamFdIndex)
+	syscall.CloseOnExec(monitorStatusFdIndex)
+
+	statusPipe := os.NewFile(uintptr(monitorStatusFdIndex), "status")
+	param, err := readMonitorParam()
+	if err != nil {
+		_, _ = statusPipe.WriteString(err.Error())
+		_ = statusPipe.Close()
+		return err
+	}
+
+	// 监控进程持有容器的标准输入输出，并接受attach连接
+	containerName := param.ContainerName
+	if containerName == "" {
+		containerName = param.Param.ContainerName
+	}
+	hub, err := newIOHub(containerName)
+	if err != nil {
+		_, _ = statusPipe.WriteString(err.Error())
+		_ = statusPipe.Close()
+		return err
+	}
+
+	if param.ContainerName != "" {
+		containerInfo, subprocess, err := resumeContainer(param.ContainerName, hub)
+		if err != nil {
+			_, _ = statusPipe.WriteString(err.Error())
+			_ = statusPipe.Close()
+			return err
+		}
+		_ = statusPipe.Close()
+		return superviseContainer(containerInfo.Name, waitAsync(subprocess), hub)
+	}
+
+	// 新建的容器进程阻塞在管道上，直到收到启动信号后才执行用户命令
+	fifo, err := openStartFifo(param.Param.ContainerName)
+	if err != nil {
+		_, _ = statusPipe.WriteString(err.Error())
+		_ = statusPipe.Close()
+		return err
+	}
+	containerInfo, subprocess, pipes, err := createContainer(param.Id, param.Param, hub)
+	if err != nil {
+		_ = fifo.Close()
+		_, _ = statusPipe.WriteString(err.Error())
+		_ = statusPipe.Close()
+		return err
+	}
+	_ = statusPipe.Close()
+
+	waitCh := waitAsync(subprocess)
+	select {
+	case waitErr := <-waitCh:
+		// 容器在启动前已被停止
+		_, err = recordExit(containerInfo.Name, waitErr, nil, false)
+		return err
+	case err = <-waitStartSignal(fifo):
+		if err != nil {
+			pipes.Close()
+			return err
+		}
+	}
+	err = startCreatedContainer(containerInfo.Name, pipes)
+	if err == errStoppedBeforeStart {
+		_, err = recordExit(containerInfo.Name, <-waitCh, nil, false)
+		return err
+	}
+	if err != nil {
+		// 容器进程初始化失败后会自行退出，记录失败原因供basin start读取
+		_, _ = recordExit(containerInfo.Name, <-waitCh, err, false)
+		return err
+	}
+
+	return superviseContainer(containerInfo.Name, waitCh, hub)
+}
+
+// startCreatedContainer 收到启动信号后持有配置文件锁运行已创建的容器，容器在启动前已被basin stop停止时不再运行
+func startCreatedContainer(containerName string, pipes *initPipes) error {
+	unlock, err := lockContainerConfig(containerName)
+	if err != nil {
+		pipes.Close()
+		return err
+	}
+	defer unlock()
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		pipes.Close()
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.ManuallyStopped {
+		pipes.Close()
+		return errStoppedBeforeStart
+	}
+	return runContainerProcess(containerInfo, pipes)
+}
+
+// waitAsync 在后台等待容器进程退出
+func waitAsync(subprocess *exec.Cmd) <-chan error {
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- subprocess.Wait()
+	}()
+	return waitCh
+}
+
+// superviseContainer 等待容器进程退出，并按照重启策略以指数退避的方式重启容器
+func superviseContainer(containerName string, waitCh <-chan error, hub *ioHub) error {
+	backoff := restartBackoffMin
+	for {
+		startedAt := time.Now()
+		containerInfo, err := recordExit(containerName, <-waitCh, nil, true)
+		if err != nil {
+			return err
+		}
+		if containerInfo.Status != common.Restarting {
+			return nil
+		}
+
+		if time.Since(startedAt) > restartBackoffReset {
+			backoff = restartBackoffMin
+		}
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff)
+
+		subprocess, err := restartContainer(containerName, hub)
+		if err != nil {
+			return err
+		}
+		if subprocess == nil {
+			return nil
+		}
+		waitCh = waitAsync(subprocess)
+	}
+}
+
+// restartContainer 重新启动等待重启的容器，容器在等待期间已被手动停止时返回nil。
+// 启动过程中持有配置文件锁，使basin stop要么在启动前将容器标记为stopped，要么在启动后停止运行中的容器
+func restartContainer(containerName string, hub *ioHub) (*exec.Cmd, error) {
+	unlock, err := lockContainerConfig(containerName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status != common.Restarting {
+		return nil, nil
+	}
+
+	containerInfo.RestartCount++
+	subprocess, err := launchContainer(containerInfo, hub)
+	if err != nil {
+		containerInfo.Status = common.Exit
+		containerInfo.ExitReason = "restart failed"
+		containerInfo.Error = err.Error()
+		_ = recordConfig(containerInfo)
+		return nil, errors.Wrapf(err, "restart container %s", containerName)
+	}
+	return subprocess, nil
+}
+
+func readMonitorParam() (*monitorParam, error) {
+	pipe := os.NewFile(uintptr(monitorParamFdIndex), "pipe")
+	defer pipe.Close()
+
+	param := new(monitorParam)
+	if err := json.NewDecoder(pipe).Decode(param); err != nil {
+		return nil, errors.Wrap(err, "read monitor param")
+	}
+	return param, nil
+}
+
+// recordExit 将容器进程的退出信息写入配置文件，已被stop的容器保持stopped状态。
+// startErr为容器进程初始化失败的原因；restart为true时，需按照重启策略重启的容器在同一次更新中标记为restarting，
+// 避免basin stop恰好在两次更新之间读到已退出的状态而未能阻止重启
+func recordExit(containerName string, waitErr error, startErr error, restart bool) (*common.BaseConfig, error) {
+	containerInfo, err := updateContainerInfo(containerName, func(containerInfo *common.BaseConfig) error {
+		// 容器已由新的监控进程接管时不再覆盖其状态
+		if containerInfo.MonitorPid != os.Getpid() {
+			return errors.Errorf("container %s is owned by monitor %d", containerName, containerInfo.MonitorPid)
+		}
+		applyExit(containerInfo, waitErr, startErr, restart)
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("record container %s exit err: %v", containerName, err)
+		return nil, err
+	}
+	return containerInfo, nil
+}
+
+// applyExit 将容器进程的退出信息写入容器配置，已被手动停止的容器标记为stopped，否则标记为exited；
+// restart为true且需按照重启策略重启时标记为restarting
+func applyExit(containerInfo *common.BaseConfig, waitErr error, startErr error, restart bool) {
+	containerInfo.ExitCode, containerInfo.ExitReason = exitStatus(waitErr)
+	containerInfo.FinishedTime = time.Now().Format("2006-01-02 15:04:05")
+	containerInfo.Pid = ""
+	if startErr != nil {
+		containerInfo.Error = startErr.Error()
+	}
+	if containerInfo.ManuallyStopped {
+		containerInfo.Status = common.Stop
+	} else {
+		containerInfo.Status = common.Exit
+	}
+	if restart && shouldRestart(containerInfo) {
+		containerInfo.Status = common.Restarting
+	}
+}
+
+// exitStatus 解析容器进程的退出码及退出原因
+func exitStatus(waitErr error) (int, string) {
+	if waitErr == nil {
+		return 0, "completed"
+	}
+	code, err := exitCode(waitErr)
+	if err != nil {
+		return -1, err.Error()
+	}
+	if status, ok := waitErr.(*exec.ExitError).Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return code, fmt.Sprintf("killed by signal %d (%s)", status.Signal(), status.Signal())
+	}
+	return code, fmt.Sprintf("exited with code %d", code)
+}
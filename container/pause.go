@@ -0,0 +1,45 @@
+package container
+
+import (
+	"github.com/liruonian/basin/cgroup"
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// Pause 通过freezer冻结容器中的全部进程
+func Pause(containerName string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	_, err = updateContainerInfo(containerName, func(containerInfo *common.BaseConfig) error {
+		if containerInfo.Status != common.Running {
+			return errors.Errorf("container %s is not running", containerName)
+		}
+		if err := cgroup.NewCgroupManager(containerInfo.CgroupPath).Freeze(); err != nil {
+			return errors.Wrapf(err, "pause container %s", containerName)
+		}
+		containerInfo.Status = common.Paused
+		return nil
+	})
+	return err
+}
+
+// Unpause 解冻已暂停容器中的全部进程
+func Unpause(containerName string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	_, err = updateContainerInfo(containerName, func(containerInfo *common.BaseConfig) error {
+		if containerInfo.Status != common.Paused {
+			return errors.Errorf("container %s is not paused", containerName)
+		}
+		if err := cgroup.NewCgroupManager(containerInfo.CgroupPath).Thaw(); err != nil {
+			return errors.Wrapf(err, "unpause container %s", containerName)
+		}
+		containerInfo.Status = common.Running
+		return nil
+	})
+	return err
+}
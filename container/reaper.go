@@ -0,0 +1,165 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// mfdExec 即MFD_EXEC，vm.memfd_noexec为1时需显式指定才能执行memfd中的程序，较早的内核不支持该标记
+const mfdExec = 0x10
+
+// unforwardedSignals 不转发给用户进程的信号：SIGCHLD用于回收子进程，SIGURG为go运行时抢占调度使用的信号，
+// 其余为无法捕获或由进程自身的错误同步产生的信号
+var unforwardedSignals = map[syscall.Signal]bool{
+	syscall.SIGKILL: true,
+	syscall.SIGSTOP: true,
+	syscall.SIGCHLD: true,
+	syscall.SIGURG:  true,
+	syscall.SIGILL:  true,
+	syscall.SIGTRAP: true,
+	syscall.SIGABRT: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGSEGV: true,
+	syscall.SIGSYS:  true,
+	syscall.SIGPIPE: true,
+	syscall.SIGTTIN: true,
+	syscall.SIGTTOU: true,
+}
+
+// cloneReaperBinary 将basin二进制复制到密封的memfd中。reaper从该副本执行，容器中的进程通过/proc/1/exe
+// 只能访问到无法修改的副本，而不是宿主机上的basin二进制
+func cloneReaperBinary() (*os.File, error) {
+	fd, err := unix.MemfdCreate("basin", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING|mfdExec)
+	if err == unix.EINVAL {
+		fd, err = unix.MemfdCreate("basin", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "create memfd")
+	}
+	memfd := os.NewFile(uintptr(fd), "basin")
+
+	exe, err := os.Open("/proc/self/exe")
+	if err != nil {
+		_ = memfd.Close()
+		return nil, errors.Wrap(err, "open /proc/self/exe")
+	}
+	defer exe.Close()
+	if _, err = io.Copy(memfd, exe); err != nil {
+		_ = memfd.Close()
+		return nil, errors.Wrap(err, "copy basin binary")
+	}
+	seals := unix.F_SEAL_SEAL | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE
+	if _, err = unix.FcntlInt(memfd.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		_ = memfd.Close()
+		return nil, errors.Wrap(err, "seal memfd")
+	}
+	return memfd, nil
+}
+
+// execReaper 以basin reaper替换当前进程，需在切换用户后调用。capability只对调用的线程生效，
+// 重新执行后进程的全部线程及用户进程都只具有与直接执行用户命令时相同的用户及capability。
+// reaper为cloneReaperBinary复制的basin二进制，设置了close-on-exec，执行后不会被reaper及用户进程继承
+func execReaper(reaper *os.File, path string, spec *common.ProcessSpec) error {
+	// 错误管道由reaper在创建用户进程后关闭
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_FCNTL, errFdIndex, syscall.F_SETFD, 0); errno != 0 {
+		return errors.Wrap(errno, "clear close-on-exec of error pipe")
+	}
+	args := append([]string{os.Args[0], "reaper", path}, spec.Args...)
+	if err := syscall.Exec(fmt.Sprintf("/proc/self/fd/%d", reaper.Fd()), args, spec.Env); err != nil {
+		return errors.Wrap(err, "exec reaper")
+	}
+	return nil
+}
+
+// RunContainerReaper 作为容器的1号进程执行args[0]，args[1:]为用户命令的参数，环境变量沿用当前进程的环境变量
+func RunContainerReaper(args []string) error {
+	syscall.CloseOnExec(errFdIndex)
+	errPipe := os.NewFile(uintptr(errFdIndex), "errpipe")
+
+	err := errors.New("container command is empty")
+	if len(args) >= 2 {
+		err = runInit(args[0], args[1:], errPipe)
+	}
+	if err != nil {
+		logrus.Errorf("init container process failed: %v", err)
+		_, _ = errPipe.WriteString(err.Error())
+		_ = errPipe.Close()
+		return err
+	}
+	return nil
+}
+
+// runInit 以1号进程的身份创建用户进程，将收到的信号转发给用户进程，并回收容器中的孤儿进程，
+// 用户进程退出后以其退出码退出
+func runInit(path string, args []string, errPipe *os.File) error {
+	// 需在创建用户进程前注册，避免遗漏其退出时的SIGCHLD
+	signalCh := make(chan os.Signal, 32)
+	signal.Notify(signalCh, syscall.SIGCHLD)
+	for i := 1; i <= 31; i++ {
+		if sig := syscall.Signal(i); !unforwardedSignals[sig] {
+			signal.Notify(signalCh, sig)
+		}
+	}
+
+	// 用户进程位于单独的进程组，开启TTY时作为终端的前台进程组，终端产生的SIGINT等信号只发送给用户进程，
+	// 不会再经由本进程重复转发
+	sysProcAttr := &syscall.SysProcAttr{Setpgid: true}
+	if isTerminal(os.Stdin.Fd()) {
+		sysProcAttr.Foreground = true
+		sysProcAttr.Ctty = int(os.Stdin.Fd())
+	}
+	pid, err := syscall.ForkExec(path, args, &syscall.ProcAttr{
+		Env:   os.Environ(),
+		Files: []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()},
+		Sys:   sysProcAttr,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "exec %s", path)
+	}
+	// 用户进程创建成功即视为容器启动成功
+	_ = errPipe.Close()
+
+	for sig := range signalCh {
+		switch sig {
+		case syscall.SIGCHLD:
+			if status, exited := reapChildren(pid); exited {
+				os.Exit(waitStatusCode(status))
+			}
+		default:
+			if err = syscall.Kill(pid, sig.(syscall.Signal)); err != nil && err != syscall.ESRCH {
+				logrus.Warnf("forward signal %s to pid %d failed: %v", sig, pid, err)
+			}
+		}
+	}
+	return nil
+}
+
+// reapChildren 回收全部已退出的子进程，返回用户进程是否已退出及其退出状态
+func reapChildren(pid int) (syscall.WaitStatus, bool) {
+	var (
+		status syscall.WaitStatus
+		exited bool
+	)
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || wpid <= 0 {
+			return status, exited
+		}
+		if wpid == pid {
+			status, exited = ws, true
+		}
+	}
+}
@@ -3,28 +3,150 @@ package container
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/liruonian/basin/cgroup"
 	"github.com/liruonian/basin/common"
+	"github.com/liruonian/basin/network"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-func Remove(containerName string) {
+// Remove 删除容器，force为true时先强制停止运行中的容器，volumes为true时同时删除容器的匿名卷
+func Remove(containerName string, force, volumes bool) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
 	containerInfo, err := getContainerInfoByName(containerName)
 	if err != nil {
-		logrus.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+
+	switch containerInfo.Status {
+	case common.Stop, common.Exit:
+		// 原监控进程记录退出信息后仍在运行时说明容器尚未完全停止
+		if !waitProcessExit(containerInfo.MonitorPid, time.Second) {
+			return errors.Errorf("container %s is still stopping", containerName)
+		}
+	case common.Created:
+		// 已创建但未启动的容器可以直接删除，先停止阻塞中的容器进程
+		if err = Stop(containerName, DefaultStopTimeout); err != nil {
+			return errors.Wrapf(err, "stop created container %s", containerName)
+		}
+	default:
+		if !force {
+			return errors.Errorf("couldn't remove %s container %s, stop it before removing or use -f", containerInfo.Status, containerName)
+		}
+		if err = Stop(containerName, 0); err != nil {
+			return errors.Wrapf(err, "stop container %s", containerName)
+		}
 	}
 
-	if containerInfo.Status != common.Stop {
-		logrus.Errorf("Couldn't remove running container")
-		return
+	removeContainer(containerInfo, volumes)
+	return nil
+}
+
+// Prune 删除全部已停止及已退出的容器，并打印回收的磁盘空间
+func Prune() error {
+	infos, err := listContainerInfos()
+	if err != nil {
+		return err
 	}
+
+	var reclaimed uint64
+	fmt.Println("Deleted Containers:")
+	for _, info := range infos {
+		if info.Status != common.Stop && info.Status != common.Exit {
+			continue
+		}
+		size, removed := pruneContainer(info.Name)
+		if removed {
+			reclaimed += size
+			fmt.Println(info.Id)
+		}
+	}
+	fmt.Printf("\nTotal reclaimed space: %s\n", formatBytes(reclaimed))
+	return nil
+}
+
+// pruneContainer 持有配置文件锁确认容器已停止且其监控进程已退出后删除容器，避免删除正在启动或仍在退出的容器
+func pruneContainer(containerName string) (uint64, bool) {
+	unlock, err := lockContainerConfig(containerName)
+	if err != nil {
+		logrus.Warnf("skip container %s: %v", containerName, err)
+		return 0, false
+	}
+	defer unlock()
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		logrus.Warnf("skip container %s: %v", containerName, err)
+		return 0, false
+	}
+	if containerInfo.Status != common.Stop && containerInfo.Status != common.Exit {
+		return 0, false
+	}
+	if !waitProcessExit(containerInfo.MonitorPid, time.Second) {
+		logrus.Warnf("skip container %s, it is still stopping", containerName)
+		return 0, false
+	}
+	return removeContainer(containerInfo, false), true
+}
+
+// removeContainer 删除已停止容器的数据目录、workspace、cgroup及网络资源，返回回收的磁盘空间。
+// 清理过程中的错误仅记录日志，尽可能释放全部资源
+func removeContainer(containerInfo *common.BaseConfig, volumes bool) uint64 {
+	containerName := containerInfo.Name
 	dirURL := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
+	rootURL := common.RootUrl + containerName
+	// merged层是lower及upper层的联合挂载，不重复统计
+	reclaimed := diskUsage(dirURL, "") + diskUsage(rootURL, fmt.Sprintf(common.MergedDirFormat, containerName))
+	if volumes && containerInfo.AnonymousVolume != "" {
+		reclaimed += diskUsage(containerInfo.AnonymousVolume, "")
+	}
+
+	if containerInfo.Spec != nil && containerInfo.Spec.Network != "" && containerInfo.IPAddress != "" {
+		if err := network.Init(); err != nil {
+			logrus.Errorf("Init network error %v", err)
+		} else if err = network.Disconnect(containerInfo.Spec.Network, containerInfo); err != nil {
+			logrus.Errorf("Disconnect container %s from network %s error %v", containerName, containerInfo.Spec.Network, err)
+		}
+	}
 	if err := os.RemoveAll(dirURL); err != nil {
 		logrus.Errorf("Remove file %s error %v", dirURL, err)
 	}
-	err = deleteWorkSpace(containerName, containerInfo.Volume)
-	if err != nil {
+	if err := deleteWorkSpace(containerName, containerInfo.Volume); err != nil {
 		logrus.Errorf("DeleteWorkSpace error %v", err)
 	}
+	if volumes && containerInfo.AnonymousVolume != "" {
+		if err := os.RemoveAll(containerInfo.AnonymousVolume); err != nil {
+			logrus.Errorf("Remove volume %s error %v", containerInfo.AnonymousVolume, err)
+		}
+	}
+	if containerInfo.CgroupPath != "" {
+		if err := cgroup.NewCgroupManager(containerInfo.CgroupPath).Destroy(); err != nil {
+			logrus.Errorf("Destroy cgroup %s error %v", containerInfo.CgroupPath, err)
+		}
+	}
+	return reclaimed
+}
+
+// diskUsage 统计目录下普通文件的大小之和，跳过skipDir目录
+func diskUsage(dir, skipDir string) uint64 {
+	var size uint64
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path == skipDir {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
 }
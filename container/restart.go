@@ -0,0 +1,79 @@
+package container
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// restartBackoffMin 首次重启前的等待时间，此后每次重启翻倍
+	restartBackoffMin = 100 * time.Millisecond
+	// restartBackoffMax 重启前的最大等待时间
+	restartBackoffMax = time.Minute
+	// restartBackoffReset 容器持续运行超过该时间后，重置重启前的等待时间
+	restartBackoffReset = 10 * time.Second
+)
+
+// ParseRestartPolicy 解析no|on-failure[:max]|always|unless-stopped格式的重启策略
+func ParseRestartPolicy(policy string) (*common.RestartPolicy, error) {
+	if policy == "" {
+		return &common.RestartPolicy{Name: common.RestartNo}, nil
+	}
+
+	parts := strings.SplitN(policy, ":", 2)
+	restartPolicy := &common.RestartPolicy{Name: parts[0]}
+	switch restartPolicy.Name {
+	case common.RestartNo, common.RestartAlways, common.RestartUnlessStopped:
+		if len(parts) == 2 {
+			return nil, errors.Errorf("maximum retry count cannot be used with restart policy %s", restartPolicy.Name)
+		}
+	case common.RestartOnFailure:
+		if len(parts) == 2 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return nil, errors.Errorf("invalid maximum retry count: %s", parts[1])
+			}
+			restartPolicy.MaximumRetryCount = count
+		}
+	default:
+		return nil, errors.Errorf("invalid restart policy: %s", policy)
+	}
+	return restartPolicy, nil
+}
+
+// shouldRestart 根据重启策略判断已退出的容器是否需要重启，被手动停止的容器不会重启。
+// unless-stopped依据配置文件中持久化的手动停止标记判断，直到再次通过basin start启动前都不会被重启，
+// 不受监控进程重新接管容器的影响
+func shouldRestart(containerInfo *common.BaseConfig) bool {
+	policy := containerInfo.Spec.RestartPolicy
+	if policy == nil || containerInfo.Status == common.Stop {
+		return false
+	}
+
+	switch policy.Name {
+	case common.RestartAlways:
+		return true
+	case common.RestartUnlessStopped:
+		return !containerInfo.ManuallyStopped
+	case common.RestartOnFailure:
+		if containerInfo.ExitCode == 0 {
+			return false
+		}
+		return policy.MaximumRetryCount == 0 || containerInfo.RestartCount < policy.MaximumRetryCount
+	default:
+		return false
+	}
+}
+
+// nextBackoff 计算下一次重启前的等待时间，采用指数退避
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > restartBackoffMax {
+		return restartBackoffMax
+	}
+	return backoff
+}
@@ -0,0 +1,142 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    *common.RestartPolicy
+		wantErr bool
+	}{
+		{policy: "", want: &common.RestartPolicy{Name: common.RestartNo}},
+		{policy: "no", want: &common.RestartPolicy{Name: common.RestartNo}},
+		{policy: "always", want: &common.RestartPolicy{Name: common.RestartAlways}},
+		{policy: "unless-stopped", want: &common.RestartPolicy{Name: common.RestartUnlessStopped}},
+		{policy: "on-failure", want: &common.RestartPolicy{Name: common.RestartOnFailure}},
+		{policy: "on-failure:3", want: &common.RestartPolicy{Name: common.RestartOnFailure, MaximumRetryCount: 3}},
+		{policy: "on-failure:-1", wantErr: true},
+		{policy: "on-failure:x", wantErr: true},
+		{policy: "always:3", wantErr: true},
+		{policy: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRestartPolicy(tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRestartPolicy(%q) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRestartPolicy(%q) = %+v, want %+v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          *common.RestartPolicy
+		status          string
+		manuallyStopped bool
+		exitCode        int
+		restartCount    int
+		want            bool
+	}{
+		{name: "no policy", status: common.Exit, want: false},
+		{name: "no", policy: &common.RestartPolicy{Name: common.RestartNo}, status: common.Exit, exitCode: 1, want: false},
+		{name: "always", policy: &common.RestartPolicy{Name: common.RestartAlways}, status: common.Exit, want: true},
+		{name: "always stopped", policy: &common.RestartPolicy{Name: common.RestartAlways}, status: common.Stop, manuallyStopped: true, want: false},
+		{name: "unless-stopped", policy: &common.RestartPolicy{Name: common.RestartUnlessStopped}, status: common.Exit, want: true},
+		{name: "unless-stopped stopped", policy: &common.RestartPolicy{Name: common.RestartUnlessStopped}, status: common.Stop, manuallyStopped: true, want: false},
+		{name: "unless-stopped stopped before exit recorded", policy: &common.RestartPolicy{Name: common.RestartUnlessStopped}, status: common.Exit, manuallyStopped: true, want: false},
+		{name: "on-failure success", policy: &common.RestartPolicy{Name: common.RestartOnFailure}, status: common.Exit, want: false},
+		{name: "on-failure failure", policy: &common.RestartPolicy{Name: common.RestartOnFailure}, status: common.Exit, exitCode: 1, want: true},
+		{name: "on-failure retries left", policy: &common.RestartPolicy{Name: common.RestartOnFailure, MaximumRetryCount: 2}, status: common.Exit, exitCode: 1, restartCount: 1, want: true},
+		{name: "on-failure retries exhausted", policy: &common.RestartPolicy{Name: common.RestartOnFailure, MaximumRetryCount: 2}, status: common.Exit, exitCode: 1, restartCount: 2, want: false},
+	}
+	for _, tt := range tests {
+		containerInfo := &common.BaseConfig{
+			Spec:            &common.RunParam{RestartPolicy: tt.policy},
+			Status:          tt.status,
+			ManuallyStopped: tt.manuallyStopped,
+			ExitCode:        tt.exitCode,
+			RestartCount:    tt.restartCount,
+		}
+		if got := shouldRestart(containerInfo); got != tt.want {
+			t.Errorf("%s: shouldRestart() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newRunningContainer 构造运行中的容器配置，用于按照basin stop与监控进程实际的更新顺序驱动容器状态
+func newRunningContainer(policy string) *common.BaseConfig {
+	return &common.BaseConfig{
+		Name:   "test",
+		Pid:    "42",
+		Status: common.Running,
+		Spec:   &common.RunParam{RestartPolicy: &common.RestartPolicy{Name: policy}},
+	}
+}
+
+func TestStopRunningContainer(t *testing.T) {
+	containerInfo := newRunningContainer(common.RestartUnlessStopped)
+	pid, stopped, err := markManuallyStopped(containerInfo)
+	if err != nil || stopped || pid != 42 {
+		t.Fatalf("markManuallyStopped() = %d, %v, %v, want 42, false, nil", pid, stopped, err)
+	}
+	// 容器进程收到停止信号退出后，监控进程记录退出信息，手动停止的标记不会被覆盖
+	applyExit(containerInfo, nil, nil, true)
+	if containerInfo.Status != common.Stop || !containerInfo.ManuallyStopped {
+		t.Errorf("status after exit = %s, manuallyStopped %v, want %s, true", containerInfo.Status, containerInfo.ManuallyStopped, common.Stop)
+	}
+	if containerInfo.Pid != "" {
+		t.Errorf("pid after exit = %q, want empty", containerInfo.Pid)
+	}
+}
+
+func TestExitRestarts(t *testing.T) {
+	for _, policy := range []string{common.RestartAlways, common.RestartUnlessStopped} {
+		containerInfo := newRunningContainer(policy)
+		applyExit(containerInfo, nil, nil, true)
+		if containerInfo.Status != common.Restarting {
+			t.Errorf("%s: status after exit = %s, want %s", policy, containerInfo.Status, common.Restarting)
+		}
+	}
+
+	// 初始化失败的容器不由监控进程重启
+	containerInfo := newRunningContainer(common.RestartAlways)
+	applyExit(containerInfo, nil, errors.New("start failed"), false)
+	if containerInfo.Status != common.Exit || containerInfo.Error != "start failed" {
+		t.Errorf("status after start failure = %s, error %q, want %s, %q", containerInfo.Status, containerInfo.Error, common.Exit, "start failed")
+	}
+}
+
+func TestStopRestartingContainer(t *testing.T) {
+	containerInfo := newRunningContainer(common.RestartAlways)
+	applyExit(containerInfo, nil, nil, true)
+	_, stopped, err := markManuallyStopped(containerInfo)
+	if err != nil || !stopped {
+		t.Fatalf("markManuallyStopped() = %v, %v, want true, nil", stopped, err)
+	}
+	// 监控进程等待结束后发现容器不再处于restarting状态，不会重启容器
+	if containerInfo.Status != common.Stop || !containerInfo.ManuallyStopped {
+		t.Errorf("status after stop = %s, manuallyStopped %v, want %s, true", containerInfo.Status, containerInfo.ManuallyStopped, common.Stop)
+	}
+}
+
+func TestStopExitedContainer(t *testing.T) {
+	containerInfo := newRunningContainer(common.RestartNo)
+	applyExit(containerInfo, nil, nil, true)
+	_, stopped, err := markManuallyStopped(containerInfo)
+	if err != nil || !stopped {
+		t.Fatalf("markManuallyStopped() = %v, %v, want true, nil", stopped, err)
+	}
+	if containerInfo.Status != common.Exit || containerInfo.ManuallyStopped {
+		t.Errorf("status after stop = %s, manuallyStopped %v, want %s, false", containerInfo.Status, containerInfo.ManuallyStopped, common.Exit)
+	}
+}
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// rlimitResources 支持通过--ulimit设置的资源类型
+var rlimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// ParseUlimit 解析形如 nofile=1024[:2048] 的资源上限，未指定hard时与soft相同，-1表示不限制
+func ParseUlimit(ulimit string) (common.Rlimit, error) {
+	parts := strings.SplitN(ulimit, "=", 2)
+	if len(parts) != 2 {
+		return common.Rlimit{}, errors.Errorf("invalid ulimit %q, expected <type>=<soft>[:<hard>]", ulimit)
+	}
+	if _, ok := rlimitResources[parts[0]]; !ok {
+		return common.Rlimit{}, errors.Errorf("invalid ulimit type %q", parts[0])
+	}
+
+	limits := strings.SplitN(parts[1], ":", 2)
+	soft, err := parseRlimitValue(limits[0])
+	if err != nil {
+		return common.Rlimit{}, errors.Wrapf(err, "invalid ulimit %q", ulimit)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = parseRlimitValue(limits[1]); err != nil {
+			return common.Rlimit{}, errors.Wrapf(err, "invalid ulimit %q", ulimit)
+		}
+	}
+	if soft > hard {
+		return common.Rlimit{}, errors.Errorf("invalid ulimit %q, soft limit must not exceed hard limit", ulimit)
+	}
+
+	return common.Rlimit{Type: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+func parseRlimitValue(value string) (uint64, error) {
+	if value == "-1" || value == "unlimited" {
+		return unix.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// parseUlimits 将运行参数中的ulimit转换为进程描述中的资源上限
+func parseUlimits(ulimits []string) ([]common.Rlimit, error) {
+	rlimits := make([]common.Rlimit, 0, len(ulimits))
+	for _, ulimit := range ulimits {
+		rlimit, err := ParseUlimit(ulimit)
+		if err != nil {
+			return nil, err
+		}
+		rlimits = append(rlimits, rlimit)
+	}
+	return rlimits, nil
+}
+
+// setRlimits 在容器进程中设置资源上限，执行用户命令后仍然生效
+func setRlimits(rlimits []common.Rlimit) error {
+	for _, rlimit := range rlimits {
+		resource, ok := rlimitResources[rlimit.Type]
+		if !ok {
+			return errors.Errorf("invalid rlimit type %q", rlimit.Type)
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}); err != nil {
+			return errors.Wrapf(err, "set rlimit %s", rlimit.Type)
+		}
+	}
+	return nil
+}
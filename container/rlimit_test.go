@@ -0,0 +1,36 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/liruonian/basin/common"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseUlimit(t *testing.T) {
+	tests := []struct {
+		ulimit  string
+		want    common.Rlimit
+		wantErr bool
+	}{
+		{ulimit: "nofile=1024", want: common.Rlimit{Type: "nofile", Soft: 1024, Hard: 1024}},
+		{ulimit: "nofile=1024:2048", want: common.Rlimit{Type: "nofile", Soft: 1024, Hard: 2048}},
+		{ulimit: "core=-1", want: common.Rlimit{Type: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}},
+		{ulimit: "memlock=64:unlimited", want: common.Rlimit{Type: "memlock", Soft: 64, Hard: unix.RLIM_INFINITY}},
+		{ulimit: "nofile=2048:1024", wantErr: true},
+		{ulimit: "nofile", wantErr: true},
+		{ulimit: "nofile=abc", wantErr: true},
+		{ulimit: "nofile=1:abc", wantErr: true},
+		{ulimit: "files=1024", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUlimit(tt.ulimit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUlimit(%q) error = %v, wantErr %v", tt.ulimit, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseUlimit(%q) = %+v, want %+v", tt.ulimit, got, tt.want)
+		}
+	}
+}
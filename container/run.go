@@ -1,11 +1,14 @@
 package container
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"strconv"
 	"strings"
 	"syscall"
@@ -18,68 +21,298 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func Run(param *common.RunParam) {
-	// 随机生成容器的id
-	containerId := randStringBytes(common.IdLength)
-	if len(param.ContainerName) == 0 {
-		param.ContainerName = containerId
+// Run 创建并启动容器。容器总是交由独立的监控进程创建，前台运行时basin run先连接容器的标准输入输出，
+// 再启动容器，容器退出后删除容器；输入detach按键序列后容器转入后台继续运行
+func Run(param *common.RunParam, detachKeys string) {
+	if err := Create(param); err != nil {
+		logrus.Errorf("create container err: %v", err)
+		return
+	}
+	if param.Detach {
+		if err := Start(param.ContainerName); err != nil {
+			logrus.Errorf("start container err: %v", err)
+		}
+		return
 	}
 
-	// TODO 创建子进程，即实际的容器进程
-	subprocess, writePipe, err := newSubprocess(param.ContainerName, param.Volume, param.ImageName, param.Envs, param.TTY)
+	// 与docker run -i一致，前台运行的容器在本地输入结束后读到EOF
+	detached, err := attachContainer(param.ContainerName, param.TTY, true, detachKeys, func() error {
+		return Start(param.ContainerName)
+	})
 	if err != nil {
-		logrus.Errorf("new subprocess err: %v", err)
+		logrus.Errorf("start container err: %v", err)
+	} else if detached {
 		return
+	} else if _, err = Wait(param.ContainerName); err != nil {
+		logrus.Errorf("wait container err: %v", err)
 	}
-	if err := subprocess.Start(); err != nil {
-		logrus.Errorf("run subprocess.Start err: %v", err)
-		return
+	// 前台运行的容器退出后连同匿名卷一起删除
+	if err = Remove(param.ContainerName, false, true); err != nil {
+		logrus.Errorf("remove container err: %v", err)
 	}
+}
 
-	// 将容器运行信息记录到配置文件中
-	err = recordConfig(subprocess.Process.Pid, containerId, param.ContainerName, param.Volume, param.ContainerCommands)
+// newContainerId 随机生成容器的id，未指定容器名时以短id作为容器名，
+// 并通过创建容器数据目录占用容器名，容器名已被使用时返回错误
+func newContainerId(param *common.RunParam) (string, error) {
+	idBytes := make([]byte, common.IdLength)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", errors.Wrap(err, "generate container id")
+	}
+	containerId := hex.EncodeToString(idBytes)
+	if len(param.ContainerName) == 0 {
+		param.ContainerName = containerId[:common.ShortIdLength]
+	}
+	if err := validateContainerName(param.ContainerName); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(common.ContainerDataUrl, common.Perm0622); err != nil {
+		return "", errors.Wrapf(err, "mkdir[%s] failed", common.ContainerDataUrl)
+	}
+	containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, param.ContainerName)
+	if err := os.Mkdir(containerDataUrl, common.Perm0622); err != nil {
+		if os.IsExist(err) {
+			return "", errors.Errorf("container name %s is already in use", param.ContainerName)
+		}
+		return "", errors.Wrapf(err, "mkdir[%s] failed", containerDataUrl)
+	}
+	return containerId, nil
+}
+
+// createContainer 创建容器的workspace及容器进程，此时容器进程阻塞在管道上等待进程描述
+func createContainer(containerId string, param *common.RunParam, hub *ioHub) (*common.BaseConfig, *exec.Cmd, *initPipes, error) {
+	// 实际处理子进程的workspace
+	volume, anonymousVolume := resolveVolume(containerId, param.Volume)
+	if err := NewWorkspace(param.ContainerName, param.ImageName, volume, param.ReadOnly); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "new workspace")
+	}
+
+	containerInfo := &common.BaseConfig{
+		Id:              containerId,
+		Name:            param.ContainerName,
+		Command:         strings.Join(param.ContainerCommands, " "),
+		Volume:          volume,
+		PortMapping:     param.PortMapping,
+		CreatedTime:     time.Now().Format("2006-01-02 15:04:05"),
+		Spec:            param,
+		CgroupPath:      path.Join(common.CgroupName, containerId),
+		Mounts:          containerMounts(param.ContainerName, volume, param.ReadOnly),
+		AnonymousVolume: anonymousVolume,
+	}
+
+	subprocess, pipes, err := createContainerProcess(containerInfo, hub)
 	if err != nil {
-		logrus.Errorf("record container config err: %v", err)
-		return
+		// 清理已创建的workspace、cgroup及网络等资源
+		removeContainer(containerInfo, true)
+		return nil, nil, nil, err
 	}
 
+	containerInfo.Status = common.Created
+	if err = recordConfig(containerInfo); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "record container config")
+	}
+	return containerInfo, subprocess, pipes, nil
+}
+
+// launchContainer 基于已有的workspace创建并运行容器进程，容器重启时会复用workspace、cgroup以及已分配的IP地址
+func launchContainer(containerInfo *common.BaseConfig, hub *ioHub) (*exec.Cmd, error) {
+	subprocess, pipes, err := createContainerProcess(containerInfo, hub)
+	if err != nil {
+		return nil, err
+	}
+	if err = runContainerProcess(containerInfo, pipes); err != nil {
+		// 初始化失败的容器进程会自行退出，回收后再返回
+		_ = subprocess.Wait()
+		return nil, err
+	}
+	return subprocess, nil
+}
+
+// createContainerProcess 创建容器进程，并为其分配cgroup、network等资源
+func createContainerProcess(containerInfo *common.BaseConfig, hub *ioHub) (*exec.Cmd, *initPipes, error) {
+	param := containerInfo.Spec
+
+	// TODO 创建子进程，即实际的容器进程
+	subprocess, pipes, err := newSubprocess(param.ContainerName, param.TTY, param.Interactive, hub)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new subprocess")
+	}
+	err = subprocess.Start()
+	// 子进程已持有管道的一端，父进程中的副本不再需要；启动失败时同样关闭
+	closeChildFiles(subprocess)
+	if err != nil {
+		pipes.Close()
+		return nil, nil, errors.Wrap(err, "subprocess start")
+	}
+
+	containerInfo.Pid = strconv.Itoa(subprocess.Process.Pid)
+	containerInfo.MonitorPid = os.Getpid()
+
 	// 根据参数信息进行资源限制，并将子进程（容器进程）加入该资源组
-	cgroupManager := cgroup.NewCgroupManager(common.CgroupName)
-	defer cgroupManager.Destroy()
-	_ = cgroupManager.Set(param.CgroupConfig)
-	_ = cgroupManager.Apply(subprocess.Process.Pid, param.CgroupConfig)
+	cgroupManager := cgroup.NewCgroupManager(containerInfo.CgroupPath)
+	if err = cgroupManager.Set(param.CgroupConfig); err != nil {
+		abortContainerProcess(subprocess, pipes)
+		return nil, nil, errors.Wrap(err, "set cgroup")
+	}
+	if err = cgroupManager.Apply(subprocess.Process.Pid, param.CgroupConfig); err != nil {
+		abortContainerProcess(subprocess, pipes)
+		return nil, nil, errors.Wrap(err, "apply cgroup")
+	}
 
-	// 如果有指定网络，则尝试将容器接入该网络
+	// 如果有指定网络，则尝试将容器接入该网络，已分配过IP的容器重新接入原地址
 	if param.Network != "" {
 		network.Init()
-		containerInfo := &common.BaseConfig{
-			Id:          containerId,
-			Pid:         strconv.Itoa(subprocess.Process.Pid),
-			Name:        param.ContainerName,
-			PortMapping: param.PortMapping,
+		if containerInfo.IPAddress == "" {
+			err = network.Connect(param.Network, containerInfo)
+		} else {
+			err = network.Reconnect(param.Network, containerInfo)
 		}
-		if err = network.Connect(param.Network, containerInfo); err != nil {
-			logrus.Errorf("connect network err: %v", err)
-			return
+		if err != nil {
+			abortContainerProcess(subprocess, pipes)
+			return nil, nil, errors.Wrap(err, "connect network")
 		}
 	}
 
-	// 当子进程状态就绪后，将容器命令发送给子进程
-	sendContainerCommand(param.ContainerCommands, writePipe)
+	// 接入网络后才能确定容器的IP地址，再写入hosts文件
+	if err = writeHostsFiles(containerInfo); err != nil {
+		abortContainerProcess(subprocess, pipes)
+		return nil, nil, err
+	}
+
+	return subprocess, pipes, nil
+}
+
+// closeChildFiles 关闭传递给子进程的文件在父进程中的副本
+func closeChildFiles(subprocess *exec.Cmd) {
+	closeFiles(subprocess.ExtraFiles)
+	// 开启TTY时标准输入与输出为同一个伪终端从设备，只关闭一次
+	stdin, _ := subprocess.Stdin.(*os.File)
+	if stdin != nil {
+		_ = stdin.Close()
+	}
+	if output, ok := subprocess.Stdout.(*os.File); ok && output != stdin {
+		_ = output.Close()
+	}
+}
+
+// closeFiles 关闭全部文件，忽略关闭时的错误
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		_ = file.Close()
+	}
+}
+
+// abortContainerProcess 关闭管道使阻塞中的容器进程读取到空的进程描述后退出，并回收该进程
+func abortContainerProcess(subprocess *exec.Cmd, pipes *initPipes) {
+	pipes.Close()
+	_ = subprocess.Wait()
+}
+
+// runContainerProcess 通知阻塞中的容器进程执行用户命令，待其执行成功后记录容器的运行状态。
+// 调用方需持有配置文件锁，且containerInfo为持锁后读取的配置
+func runContainerProcess(containerInfo *common.BaseConfig, pipes *initPipes) error {
+	spec, err := newProcessSpec(containerInfo)
+	if err != nil {
+		// 关闭管道使容器进程读取到空的进程描述后退出
+		pipes.Close()
+		return err
+	}
+
+	// 当子进程状态就绪后，将进程描述发送给子进程
+	if err = sendProcessSpec(spec, pipes); err != nil {
+		return err
+	}
 
+	containerInfo.Status = common.Running
+	containerInfo.ManuallyStopped = false
+	containerInfo.Error = ""
+	// 将容器运行信息记录到配置文件中
+	if err = recordConfig(containerInfo); err != nil {
+		return errors.Wrap(err, "record container config")
+	}
+	return nil
+}
+
+// newProcessSpec 根据容器的运行参数生成容器进程的进程描述
+func newProcessSpec(containerInfo *common.BaseConfig) (*common.ProcessSpec, error) {
+	param := containerInfo.Spec
+	rlimits, err := parseUlimits(param.Ulimits)
+	if err != nil {
+		return nil, err
+	}
+
+	// 环境变量由固定的默认值及用户指定的值组成，不继承宿主机的环境变量，指定运行用户时HOME由容器内的init进程按照该用户的主目录设置
+	hostname := containerHostname(containerInfo)
+	defaultEnvs := []string{common.DefaultPathEnv, "HOSTNAME=" + hostname}
 	if param.TTY {
-		_ = subprocess.Wait()
-		deleteContainerInfo(param.ContainerName)
-		deleteWorkSpace(param.ContainerName, param.Volume)
+		defaultEnvs = append(defaultEnvs, common.DefaultTermEnv)
 	}
+	if param.User == "" {
+		defaultEnvs = append(defaultEnvs, "HOME=/root")
+	}
+	var envs []string
+	for _, env := range defaultEnvs {
+		// 用户指定的同名环境变量优先
+		if !hasEnv(param.Envs, strings.SplitN(env, "=", 2)[0]) {
+			envs = append(envs, env)
+		}
+	}
+	cwd := param.Workdir
+	if cwd == "" {
+		cwd = "/"
+	}
+	var tmpfs []string
+	if param.ReadOnly && param.ReadOnlyTmpfs {
+		tmpfs = readonlyTmpfsPaths
+	}
+	seccomp, err := resolveSeccompProfile(param)
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.ProcessSpec{
+		Args:            param.ContainerCommands,
+		Env:             append(envs, param.Envs...),
+		Cwd:             cwd,
+		User:            param.User,
+		Hostname:        hostname,
+		Domainname:      param.Domainname,
+		Rlimits:         rlimits,
+		MaskedPaths:     param.MaskedPaths,
+		ReadonlyPaths:   param.ReadonlyPaths,
+		Readonly:        param.ReadOnly,
+		Tmpfs:           tmpfs,
+		Capabilities:    containerCapabilities(param),
+		Seccomp:         seccomp,
+		NoNewPrivileges: param.NoNewPrivileges,
+		Privileged:      param.Privileged,
+		Init:            param.Init,
+		Terminal:        param.TTY,
+	}, nil
 }
 
-func newSubprocess(containerName, volume, imageName string, envSlice []string, tty bool) (*exec.Cmd, *os.File, error) {
-	// 在子进程会通过readPipe监听命令信息，当父进程（本进程）为子进程分配好cgroup、network等资源后，在执行实际的逻辑
+func newSubprocess(containerName string, tty, interactive bool, hub *ioHub) (_ *exec.Cmd, _ *initPipes, err error) {
+	// 出错时关闭已创建的管道，成功后由调用方负责关闭
+	var files []*os.File
+	defer func() {
+		if err != nil {
+			closeFiles(files)
+		}
+	}()
+
+	// 在子进程会通过readPipe监听进程描述，当父进程（本进程）为子进程分配好cgroup、network等资源后，在执行实际的逻辑
 	readPipe, writePipe, err := os.Pipe()
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "new pipe error")
 	}
+	files = append(files, readPipe, writePipe)
+	// 子进程初始化失败时通过errWritePipe回传错误信息
+	errReadPipe, errWritePipe, err := os.Pipe()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new error pipe error")
+	}
+	files = append(files, errReadPipe, errWritePipe)
 
 	// `/proc/self/exe`为当前进程的运行信息，通过ReadLink可以获得当前程序的绝对路径
 	initCmd, err := os.Readlink("/proc/self/exe")
@@ -91,93 +324,94 @@ func newSubprocess(containerName, volume, imageName string, envSlice []string, t
 	subprocessCmd.SysProcAttr = &syscall.SysProcAttr{
 		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC,
 	}
-	// 开启TTY时，使用系统输入输出，不开启TTY时，输出到日志文件
-	if tty {
-		subprocessCmd.Stdin = os.Stdin
-		subprocessCmd.Stdout = os.Stdout
-		subprocessCmd.Stderr = os.Stderr
-	} else {
-		containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
-
-		if err := os.MkdirAll(containerDataUrl, common.Perm0622); err != nil {
-			return nil, nil, errors.Wrapf(err, "mkdir[%s] err while new subprocess", containerDataUrl)
-		}
-
-		containerLogFileUrl := containerDataUrl + common.LogFileName
-		containerLogFile, err := os.Create(containerLogFileUrl)
-		if err != nil {
-			return nil, nil, errors.Wrapf(err, "create file[%s] failed while new subprocess", containerLogFileUrl)
-		}
-		subprocessCmd.Stdout = containerLogFile
+	// 容器的标准输入输出由监控进程持有，开启TTY时分配伪终端作为容器的控制终端，供attach的客户端交互
+	stdin, output, consoleSocket, err := hub.newProcessIO(tty, interactive)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stdin != nil {
+		subprocessCmd.Stdin = stdin
+	}
+	if output != nil {
+		subprocessCmd.Stdout = output
+		subprocessCmd.Stderr = output
 	}
 
-	// 将readPipe以ExtraFiles的形式传递给子进程
-	subprocessCmd.ExtraFiles = []*os.File{readPipe}
-	// 设置环境变量
-	subprocessCmd.Env = append(os.Environ(), envSlice...)
+	// 将readPipe及errWritePipe以ExtraFiles的形式传递给子进程，开启TTY时再传递consoleSocket，
+	// 容器进程创建新的会话，并在容器内创建伪终端作为控制终端
+	subprocessCmd.ExtraFiles = []*os.File{readPipe, errWritePipe}
+	if consoleSocket != nil {
+		subprocessCmd.ExtraFiles = append(subprocessCmd.ExtraFiles, consoleSocket)
+		subprocessCmd.SysProcAttr.Setsid = true
+	}
 	// TODO 将overlayfs联合挂载后的目录作为子进程的默认目录
 	subprocessCmd.Dir = fmt.Sprintf(common.MergedDirFormat, containerName)
 
-	// 实际处理子进程的workspace
-	err = NewWorkspace(containerName, imageName, volume)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return subprocessCmd, writePipe, nil
+	return subprocessCmd, &initPipes{spec: writePipe, err: errReadPipe}, nil
 }
 
-func recordConfig(containerPid int, containerId, containerName, volume string, containerCommands []string) error {
-	createTime := time.Now().Format("2006-01-02 15:04:05")
-	command := strings.Join(containerCommands, " ")
-	config := &common.BaseConfig{
-		Pid:         strconv.Itoa(containerPid),
-		Id:          containerId,
-		Name:        containerName,
-		Volume:      volume,
-		Command:     command,
-		CreatedTime: createTime,
-		Status:      common.Running,
-	}
-
+func recordConfig(config *common.BaseConfig) error {
 	jsonBytes, err := json.Marshal(config)
 	if err != nil {
 		return errors.Wrap(err, "record container info err")
 	}
 
-	containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
+	containerDataUrl := fmt.Sprintf(common.ContainerDataUrlFormat, config.Name)
 	if err = os.MkdirAll(containerDataUrl, common.Perm0622); err != nil {
 		return errors.Wrapf(err, "mkdir[%s] failed", containerDataUrl)
 	}
 
+	// 监控进程与basin命令会同时读写配置文件，先写入临时文件再重命名，避免读到不完整的内容
 	containerConfigFileUrl := containerDataUrl + "/" + common.ConfigFileName
-	containerConfigFile, err := os.Create(containerConfigFileUrl)
-	defer containerConfigFile.Close()
+	tmpConfigFileUrl := containerConfigFileUrl + ".tmp"
+	containerConfigFile, err := os.Create(tmpConfigFileUrl)
 	if err != nil {
-		return errors.Wrapf(err, "create containerConfigFile[%s] failed", containerConfigFileUrl)
+		return errors.Wrapf(err, "create containerConfigFile[%s] failed", tmpConfigFileUrl)
 	}
-
-	if _, err = containerConfigFile.WriteString(string(jsonBytes)); err != nil {
-		return errors.Wrapf(err, "write container config file[%s] failed", containerConfigFileUrl)
+	_, err = containerConfigFile.WriteString(string(jsonBytes))
+	_ = containerConfigFile.Close()
+	if err != nil {
+		_ = os.Remove(tmpConfigFileUrl)
+		return errors.Wrapf(err, "write container config file[%s] failed", tmpConfigFileUrl)
+	}
+	if err = os.Rename(tmpConfigFileUrl, containerConfigFileUrl); err != nil {
+		_ = os.Remove(tmpConfigFileUrl)
+		return errors.Wrapf(err, "rename container config file[%s] failed", containerConfigFileUrl)
 	}
 
 	return nil
 }
 
-func sendContainerCommand(containerCommand []string, writePipe *os.File) {
-	commandLine := strings.Join(containerCommand, " ")
-	_, _ = writePipe.WriteString(commandLine)
-	_ = writePipe.Close()
+// initPipes 父进程与容器进程间的管道，spec用于发送进程描述，err用于读取容器进程的初始化错误
+type initPipes struct {
+	spec *os.File
+	err  *os.File
+}
+
+func (p *initPipes) Close() {
+	_ = p.spec.Close()
+	_ = p.err.Close()
 }
 
-func randStringBytes(n int) string {
-	letters := "1234567890"
-	rand.Seed(time.Now().UnixNano())
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+// sendProcessSpec 发送进程描述并等待容器进程执行用户命令。执行成功后错误管道随之关闭，
+// 否则读取容器进程回传的错误信息
+func sendProcessSpec(spec *common.ProcessSpec, pipes *initPipes) error {
+	defer pipes.Close()
+
+	err := json.NewEncoder(pipes.spec).Encode(spec)
+	_ = pipes.spec.Close()
+	if err != nil {
+		return errors.Wrap(err, "send process spec")
 	}
-	return string(b)
+
+	msg, err := ioutil.ReadAll(pipes.err)
+	if err != nil {
+		return errors.Wrap(err, "read container init error")
+	}
+	if len(msg) > 0 {
+		return errors.New(string(msg))
+	}
+	return nil
 }
 
 func deleteContainerInfo(containerName string) {
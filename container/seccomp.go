@@ -0,0 +1,446 @@
+package container
+
+import (
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// SeccompUnconfined 不对容器启用seccomp
+const SeccompUnconfined = "unconfined"
+
+// seccomp过滤器的返回值，高16位为动作，低16位为附加数据
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetKillThread  = 0x00000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetTrace       = 0x7ff00000
+	seccompRetLog         = 0x7ffc0000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// seccomp_data中各字段的偏移，参数为64位，小端序下低32位在前
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+
+	// seccompX32SyscallBit x32 ABI的系统调用编号标志
+	seccompX32SyscallBit = 0x40000000
+)
+
+// resolveSeccompProfile 返回容器使用的seccomp配置，未启用时返回nil
+func resolveSeccompProfile(param *common.RunParam) (*common.Seccomp, error) {
+	switch param.SeccompProfile {
+	case "":
+		if !seccompSupported {
+			logrus.Warnf("seccomp is not supported on architecture %s, the default profile is not applied", runtime.GOARCH)
+			return nil, nil
+		}
+		return defaultSeccompProfile, nil
+	case SeccompUnconfined:
+		return nil, nil
+	}
+	profile := new(common.Seccomp)
+	if err := json.Unmarshal([]byte(param.SeccompProfile), profile); err != nil {
+		return nil, errors.Wrap(err, "decode seccomp profile")
+	}
+	return profile, nil
+}
+
+// containerSeccompFilter 按照容器的seccomp配置及capability编译BPF程序，未启用seccomp时返回nil
+func containerSeccompFilter(param *common.RunParam) ([]unix.SockFilter, error) {
+	profile, err := resolveSeccompProfile(param)
+	if err != nil {
+		return nil, err
+	}
+	return compileSeccomp(profile, containerCapabilities(param))
+}
+
+// compileSeccomp 将seccomp配置编译为当前架构的BPF程序，规则按照配置中的顺序匹配，第一条命中的规则生效。
+// caps为容器保留的capability，用于判断规则的includes及excludes条件。
+// 未使用libseccomp-golang：其依赖cgo及libseccomp的C库，构建及运行basin的环境都需要安装对应版本的libseccomp，
+// 而basin只需按照docker格式的配置生成单一架构的过滤器，直接生成BPF指令即可
+func compileSeccomp(profile *common.Seccomp, caps []string) ([]unix.SockFilter, error) {
+	if profile == nil {
+		return nil, nil
+	}
+	if !seccompSupported {
+		return nil, errors.Errorf("seccomp is not supported on architecture %s", runtime.GOARCH)
+	}
+	if !seccompSupportsNativeArch(profile) {
+		return nil, errors.Errorf("seccomp profile does not support architecture %s", nativeArchName)
+	}
+	defaultAction, err := seccompAction(profile.DefaultAction, profile.DefaultErrnoRet)
+	if err != nil {
+		return nil, errors.Wrap(err, "default action")
+	}
+
+	prog := new(bpfProgram)
+	// 其他架构及x86_64上x32 ABI的系统调用编号与当前架构不同，直接终止进程，避免绕过规则
+	prog.stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch)
+	prog.jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nativeArch, "arch_ok", "")
+	prog.stmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess)
+	prog.label("arch_ok")
+	prog.stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr)
+	prog.jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, seccompX32SyscallBit, "", "nr_ok")
+	prog.stmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess)
+	prog.label("nr_ok")
+
+	enabledCaps := make(map[string]bool, len(caps))
+	for _, name := range caps {
+		enabledCaps[name] = true
+	}
+	kernel := kernelVersion()
+	for i, rule := range profile.Syscalls {
+		if !seccompRuleEnabled(rule, enabledCaps, kernel) {
+			continue
+		}
+		action, err := seccompAction(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "syscall rule %d", i)
+		}
+
+		names := rule.Names
+		if rule.Name != "" {
+			names = append([]string{rule.Name}, names...)
+		}
+		var nrs []uint32
+		for _, name := range names {
+			// 与libseccomp一致，忽略当前架构不存在的系统调用
+			nr, ok := syscallNumbers[name]
+			if !ok {
+				logrus.Debugf("seccomp: syscall %s is not supported on %s, skip it", name, nativeArchName)
+				continue
+			}
+			nrs = append(nrs, nr)
+		}
+		if err = prog.rule(nrs, rule.Args, action); err != nil {
+			return nil, errors.Wrapf(err, "syscall rule %d", i)
+		}
+	}
+	prog.stmt(unix.BPF_RET|unix.BPF_K, defaultAction)
+
+	return prog.assemble()
+}
+
+// seccompSupportsNativeArch 判断配置是否适用于当前架构，未指定架构时适用于全部架构
+func seccompSupportsNativeArch(profile *common.Seccomp) bool {
+	if len(profile.Architectures) == 0 && len(profile.ArchMap) == 0 {
+		return true
+	}
+	for _, arch := range profile.Architectures {
+		if arch == nativeArchName {
+			return true
+		}
+	}
+	for _, archMap := range profile.ArchMap {
+		if archMap.Arch == nativeArchName {
+			return true
+		}
+	}
+	return false
+}
+
+// seccompAction 将配置中的动作转换为过滤器的返回值，ERRNO默认返回EPERM
+func seccompAction(action string, errnoRet *uint32) (uint32, error) {
+	errno := uint32(syscall.EPERM)
+	if errnoRet != nil {
+		errno = *errnoRet
+	}
+	switch action {
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return seccompRetKillThread, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return seccompRetKillProcess, nil
+	case "SCMP_ACT_TRAP":
+		return seccompRetTrap, nil
+	case "SCMP_ACT_ERRNO":
+		return seccompRetErrno | errno&0xffff, nil
+	case "SCMP_ACT_TRACE":
+		return seccompRetTrace | errno&0xffff, nil
+	case "SCMP_ACT_LOG":
+		return seccompRetLog, nil
+	case "SCMP_ACT_ALLOW":
+		return seccompRetAllow, nil
+	}
+	return 0, errors.Errorf("unsupported seccomp action %q", action)
+}
+
+// seccompRuleEnabled 判断规则在当前容器中是否生效
+func seccompRuleEnabled(rule common.SeccompSyscall, caps map[string]bool, kernel [2]int) bool {
+	if rule.Includes != nil {
+		for _, name := range rule.Includes.Caps {
+			if !caps[name] {
+				return false
+			}
+		}
+		if len(rule.Includes.Arches) > 0 && !containsString(rule.Includes.Arches, runtime.GOARCH) {
+			return false
+		}
+		if rule.Includes.MinKernel != "" && !kernelAtLeast(kernel, rule.Includes.MinKernel) {
+			return false
+		}
+	}
+	if rule.Excludes != nil {
+		for _, name := range rule.Excludes.Caps {
+			if caps[name] {
+				return false
+			}
+		}
+		if containsString(rule.Excludes.Arches, runtime.GOARCH) {
+			return false
+		}
+		if rule.Excludes.MinKernel != "" && kernelAtLeast(kernel, rule.Excludes.MinKernel) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// kernelVersion 返回当前内核的主版本号及次版本号
+func kernelVersion() [2]int {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return [2]int{}
+	}
+	release := string(uts.Release[:])
+	if i := strings.IndexByte(release, 0); i >= 0 {
+		release = release[:i]
+	}
+	return parseKernelVersion(release)
+}
+
+// parseKernelVersion 解析形如5.10或5.10.0-generic的内核版本
+func parseKernelVersion(release string) [2]int {
+	var version [2]int
+	parts := strings.SplitN(release, ".", 3)
+	for i := 0; i < len(parts) && i < 2; i++ {
+		digits := parts[i]
+		if j := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+			digits = digits[:j]
+		}
+		version[i], _ = strconv.Atoi(digits)
+	}
+	return version
+}
+
+func kernelAtLeast(kernel [2]int, minKernel string) bool {
+	min := parseKernelVersion(minKernel)
+	return kernel[0] > min[0] || kernel[0] == min[0] && kernel[1] >= min[1]
+}
+
+// loadSeccomp 为当前线程加载seccomp过滤器，之后创建及执行的进程均继承该过滤器。
+// 未设置no_new_privs时需要CAP_SYS_ADMIN
+func loadSeccomp(filter []unix.SockFilter) error {
+	if len(filter) == 0 {
+		return nil
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return errors.Wrap(err, "load seccomp filter")
+	}
+	return nil
+}
+
+// bpfInstruction 待汇编的BPF指令，跳转目标以标签表示
+type bpfInstruction struct {
+	filter  unix.SockFilter
+	jtLabel string
+	jfLabel string
+}
+
+// bpfProgram 按顺序生成BPF指令，汇编时将标签转换为相对跳转偏移
+type bpfProgram struct {
+	instructions []bpfInstruction
+	labels       map[string]int
+	seq          int
+}
+
+func (p *bpfProgram) stmt(code uint16, k uint32) {
+	p.instructions = append(p.instructions, bpfInstruction{filter: unix.SockFilter{Code: code, K: k}})
+}
+
+// jump 生成条件跳转指令，标签为空时继续执行下一条指令
+func (p *bpfProgram) jump(code uint16, k uint32, jt, jf string) {
+	p.instructions = append(p.instructions, bpfInstruction{
+		filter:  unix.SockFilter{Code: code, K: k},
+		jtLabel: jt,
+		jfLabel: jf,
+	})
+}
+
+// label 将标签指向下一条指令
+func (p *bpfProgram) label(name string) {
+	if p.labels == nil {
+		p.labels = make(map[string]int)
+	}
+	p.labels[name] = len(p.instructions)
+}
+
+// newLabel 生成唯一的标签名
+func (p *bpfProgram) newLabel() string {
+	p.seq++
+	return "L" + strconv.Itoa(p.seq)
+}
+
+// rule 生成匹配系统调用编号及参数后返回action的指令
+func (p *bpfProgram) rule(nrs []uint32, args []common.SeccompArg, action uint32) error {
+	if len(args) == 0 {
+		// 条件跳转的偏移只有8位，系统调用较多时分批比较
+		for start := 0; start < len(nrs); start += 128 {
+			end := start + 128
+			if end > len(nrs) {
+				end = len(nrs)
+			}
+			match, next := p.newLabel(), p.newLabel()
+			p.stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr)
+			for _, nr := range nrs[start:end] {
+				p.jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, match, "")
+			}
+			p.jump(unix.BPF_JMP|unix.BPF_JA, 0, next, "")
+			p.label(match)
+			p.stmt(unix.BPF_RET|unix.BPF_K, action)
+			p.label(next)
+		}
+		return nil
+	}
+
+	for _, nr := range nrs {
+		next := p.newLabel()
+		p.stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr)
+		p.jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, "", next)
+		for _, arg := range args {
+			if err := p.arg(arg, next); err != nil {
+				return err
+			}
+		}
+		p.stmt(unix.BPF_RET|unix.BPF_K, action)
+		p.label(next)
+	}
+	return nil
+}
+
+// arg 生成64位参数比较的指令，条件不满足时跳转到fail，先比较高32位再比较低32位
+func (p *bpfProgram) arg(arg common.SeccompArg, fail string) error {
+	if arg.Index > 5 {
+		return errors.Errorf("invalid argument index %d", arg.Index)
+	}
+	lo := uint32(seccompDataArgs + 8*arg.Index)
+	hi := lo + 4
+	value, valueHi := uint32(arg.Value), uint32(arg.Value>>32)
+	pass := p.newLabel()
+
+	const (
+		jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jgt = unix.BPF_JMP | unix.BPF_JGT | unix.BPF_K
+		jge = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ld  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+	)
+	switch arg.Op {
+	case "SCMP_CMP_EQ":
+		p.stmt(ld, hi)
+		p.jump(jeq, valueHi, "", fail)
+		p.stmt(ld, lo)
+		p.jump(jeq, value, "", fail)
+	case "SCMP_CMP_NE":
+		p.stmt(ld, hi)
+		p.jump(jeq, valueHi, "", pass)
+		p.stmt(ld, lo)
+		p.jump(jeq, value, fail, "")
+	case "SCMP_CMP_MASKED_EQ":
+		p.stmt(ld, hi)
+		p.stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, valueHi)
+		p.jump(jeq, uint32(arg.ValueTwo>>32), "", fail)
+		p.stmt(ld, lo)
+		p.stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, value)
+		p.jump(jeq, uint32(arg.ValueTwo), "", fail)
+	case "SCMP_CMP_GT", "SCMP_CMP_GE":
+		p.stmt(ld, hi)
+		p.jump(jgt, valueHi, pass, "")
+		p.jump(jeq, valueHi, "", fail)
+		p.stmt(ld, lo)
+		if arg.Op == "SCMP_CMP_GT" {
+			p.jump(jgt, value, "", fail)
+		} else {
+			p.jump(jge, value, "", fail)
+		}
+	case "SCMP_CMP_LT", "SCMP_CMP_LE":
+		p.stmt(ld, hi)
+		p.jump(jgt, valueHi, fail, "")
+		p.jump(jeq, valueHi, "", pass)
+		p.stmt(ld, lo)
+		if arg.Op == "SCMP_CMP_LT" {
+			p.jump(jge, value, fail, "")
+		} else {
+			p.jump(jgt, value, fail, "")
+		}
+	default:
+		return errors.Errorf("unsupported seccomp operator %q", arg.Op)
+	}
+	p.label(pass)
+	return nil
+}
+
+// assemble 解析跳转标签，生成最终的BPF程序
+func (p *bpfProgram) assemble() ([]unix.SockFilter, error) {
+	// 内核限制单个过滤器的指令数
+	const maxInstructions = 4096
+	if len(p.instructions) > maxInstructions {
+		return nil, errors.Errorf("seccomp filter is too large: %d instructions", len(p.instructions))
+	}
+
+	filter := make([]unix.SockFilter, len(p.instructions))
+	for i, ins := range p.instructions {
+		filter[i] = ins.filter
+		offset := func(label string) (int, error) {
+			if label == "" {
+				return 0, nil
+			}
+			target, ok := p.labels[label]
+			if !ok {
+				return 0, errors.Errorf("undefined label %s", label)
+			}
+			return target - i - 1, nil
+		}
+		jt, err := offset(ins.jtLabel)
+		if err != nil {
+			return nil, err
+		}
+		jf, err := offset(ins.jfLabel)
+		if err != nil {
+			return nil, err
+		}
+		// 无条件跳转的偏移保存在K中
+		if ins.filter.Code == unix.BPF_JMP|unix.BPF_JA {
+			filter[i].K = uint32(jt)
+			continue
+		}
+		if jt > 255 || jf > 255 {
+			return nil, errors.Errorf("seccomp jump at instruction %d is out of range", i)
+		}
+		filter[i].Jt, filter[i].Jf = uint8(jt), uint8(jf)
+	}
+	return filter, nil
+}
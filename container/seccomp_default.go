@@ -0,0 +1,125 @@
+package container
+
+import (
+	"syscall"
+
+	"github.com/liruonian/basin/common"
+)
+
+// errnoENOSYS 返回ENOSYS时glibc会回退到旧的系统调用
+var errnoENOSYS = uint32(syscall.ENOSYS)
+
+// namespaceFlags 创建新命名空间的clone标志
+var namespaceFlags = []uint64{
+	0x00020000, // CLONE_NEWNS
+	0x02000000, // CLONE_NEWCGROUP
+	0x04000000, // CLONE_NEWUTS
+	0x08000000, // CLONE_NEWIPC
+	0x10000000, // CLONE_NEWUSER
+	0x20000000, // CLONE_NEWPID
+	0x40000000, // CLONE_NEWNET
+}
+
+// defaultSeccompProfile 容器默认的seccomp配置，允许绝大多数系统调用，仅禁止可能影响宿主机内核或逃逸容器的系统调用。
+// 容器持有对应的capability时相应的规则不生效
+var defaultSeccompProfile = &common.Seccomp{
+	DefaultAction: "SCMP_ACT_ALLOW",
+	Syscalls: append([]common.SeccompSyscall{
+		{
+			Names: []string{
+				"kexec_load", "kexec_file_load", "keyctl", "add_key", "request_key",
+				"userfaultfd", "uselib", "ustat", "sysfs", "_sysctl", "lookup_dcookie",
+				"nfsservctl", "vm86", "vm86old", "create_module", "get_kernel_syms", "query_module",
+			},
+			Action:  "SCMP_ACT_ERRNO",
+			Comment: "kernel keyring, kexec and obsolete syscalls",
+		},
+		{
+			Names: []string{
+				"mount", "umount", "umount2", "pivot_root", "setns", "unshare", "quotactl", "quotactl_fd",
+				"fsopen", "fsconfig", "fsmount", "fspick", "move_mount", "open_tree", "mount_setattr",
+				"name_to_handle_at",
+			},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN"}},
+			Comment:  "mount and namespace syscalls",
+		},
+		{
+			Names:    []string{"clone3"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: &errnoENOSYS,
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN"}},
+			Comment:  "clone3 flags can not be inspected, fall back to clone",
+		},
+		{
+			Names:    []string{"bpf"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN", "CAP_BPF"}},
+		},
+		{
+			Names:    []string{"perf_event_open"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN", "CAP_PERFMON"}},
+		},
+		{
+			Names:    []string{"reboot"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_BOOT"}},
+		},
+		{
+			Names:    []string{"init_module", "finit_module", "delete_module"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_MODULE"}},
+		},
+		{
+			Names:    []string{"acct"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_PACCT"}},
+		},
+		{
+			Names:    []string{"swapon", "swapoff"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN"}},
+		},
+		{
+			Names:    []string{"settimeofday", "stime", "clock_settime", "clock_adjtime"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_TIME"}},
+		},
+		{
+			Names:    []string{"syslog"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYSLOG"}},
+		},
+		{
+			Names:    []string{"iopl", "ioperm"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_RAWIO"}},
+		},
+		{
+			Names:    []string{"open_by_handle_at"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_DAC_READ_SEARCH"}},
+		},
+		{
+			Names:    []string{"get_mempolicy", "set_mempolicy", "mbind", "move_pages"},
+			Action:   "SCMP_ACT_ERRNO",
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_NICE"}},
+		},
+	}, cloneNamespaceRules()...),
+}
+
+// cloneNamespaceRules 禁止通过clone创建新的命名空间，避免在新的user命名空间中获得mount等权限。
+// x86_64及aarch64上clone的第一个参数均为flags
+func cloneNamespaceRules() []common.SeccompSyscall {
+	rules := make([]common.SeccompSyscall, 0, len(namespaceFlags))
+	for _, flag := range namespaceFlags {
+		rules = append(rules, common.SeccompSyscall{
+			Names:    []string{"clone"},
+			Action:   "SCMP_ACT_ERRNO",
+			Args:     []common.SeccompArg{{Index: 0, Value: flag, ValueTwo: flag, Op: "SCMP_CMP_MASKED_EQ"}},
+			Excludes: &common.SeccompFilter{Caps: []string{"CAP_SYS_ADMIN"}},
+		})
+	}
+	return rules
+}
@@ -0,0 +1,15 @@
+//go:build !amd64 && !arm64
+
+package container
+
+const (
+	// nativeArch 未提供系统调用编号的架构不生成seccomp过滤器
+	nativeArch = 0
+	// nativeArchName 当前架构在seccomp配置中的名称
+	nativeArchName = ""
+	// seccompSupported 当前架构是否支持seccomp过滤器
+	seccompSupported = false
+)
+
+// syscallNumbers 当前架构的系统调用编号
+var syscallNumbers = map[string]uint32{}
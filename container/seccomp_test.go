@@ -0,0 +1,247 @@
+package container
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/liruonian/basin/common"
+	"golang.org/x/sys/unix"
+)
+
+// runSeccompFilter 解释执行编译后的BPF程序，返回其对seccomp_data的返回值
+func runSeccompFilter(t *testing.T, filter []unix.SockFilter, arch, nr uint32, args [6]uint64) uint32 {
+	t.Helper()
+	data := make([]byte, 64)
+	binary.LittleEndian.PutUint32(data[seccompDataNr:], nr)
+	binary.LittleEndian.PutUint32(data[seccompDataArch:], arch)
+	for i, arg := range args {
+		binary.LittleEndian.PutUint64(data[seccompDataArgs+8*i:], arg)
+	}
+
+	var acc uint32
+	for pc := 0; pc < len(filter); pc++ {
+		ins := filter[pc]
+		jump := func(cond bool) {
+			if cond {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		}
+		switch ins.Code {
+		case unix.BPF_LD | unix.BPF_W | unix.BPF_ABS:
+			if ins.K%4 != 0 || int(ins.K)+4 > len(data) {
+				t.Fatalf("instruction %d loads invalid offset %d", pc, ins.K)
+			}
+			acc = binary.LittleEndian.Uint32(data[ins.K:])
+		case unix.BPF_ALU | unix.BPF_AND | unix.BPF_K:
+			acc &= ins.K
+		case unix.BPF_JMP | unix.BPF_JA:
+			pc += int(ins.K)
+		case unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K:
+			jump(acc == ins.K)
+		case unix.BPF_JMP | unix.BPF_JGT | unix.BPF_K:
+			jump(acc > ins.K)
+		case unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K:
+			jump(acc >= ins.K)
+		case unix.BPF_RET | unix.BPF_K:
+			return ins.K
+		default:
+			t.Fatalf("instruction %d has unexpected code %#x", pc, ins.Code)
+		}
+	}
+	t.Fatalf("filter runs off the end")
+	return 0
+}
+
+// skipUnsupportedSeccomp 当前架构不支持seccomp时跳过测试
+func skipUnsupportedSeccomp(t *testing.T) {
+	if !seccompSupported {
+		t.Skipf("seccomp is not supported on %s", runtime.GOARCH)
+	}
+}
+
+func TestCompileSeccompArgs(t *testing.T) {
+	skipUnsupportedSeccomp(t)
+	const (
+		value    = 0x100000005
+		valueTwo = 0x100000004
+	)
+	ops := map[string]func(arg uint64) bool{
+		"SCMP_CMP_EQ":        func(arg uint64) bool { return arg == value },
+		"SCMP_CMP_NE":        func(arg uint64) bool { return arg != value },
+		"SCMP_CMP_GT":        func(arg uint64) bool { return arg > value },
+		"SCMP_CMP_GE":        func(arg uint64) bool { return arg >= value },
+		"SCMP_CMP_LT":        func(arg uint64) bool { return arg < value },
+		"SCMP_CMP_LE":        func(arg uint64) bool { return arg <= value },
+		"SCMP_CMP_MASKED_EQ": func(arg uint64) bool { return arg&value == valueTwo },
+	}
+	// 覆盖高32位小于、等于及大于value的情况，以及低32位与value的各种关系
+	argValues := []uint64{
+		0, 5, 6, 0xffffffff,
+		0x100000000, 0x100000004, 0x100000005, 0x100000006, 0x1ffffffff,
+		0x200000000, 0x200000005, 0xffffffff00000005,
+	}
+	nr := syscallNumbers["read"]
+	errno := uint32(unix.EPERM)
+	for op, want := range ops {
+		profile := &common.Seccomp{
+			DefaultAction: "SCMP_ACT_ALLOW",
+			Syscalls: []common.SeccompSyscall{{
+				Names:  []string{"read"},
+				Action: "SCMP_ACT_ERRNO",
+				Args:   []common.SeccompArg{{Index: 2, Value: value, ValueTwo: valueTwo, Op: op}},
+			}},
+		}
+		filter, err := compileSeccomp(profile, nil)
+		if err != nil {
+			t.Fatalf("%s: compileSeccomp() error = %v", op, err)
+		}
+		for _, arg := range argValues {
+			wantRet := uint32(seccompRetAllow)
+			if want(arg) {
+				wantRet = seccompRetErrno | errno
+			}
+			if got := runSeccompFilter(t, filter, nativeArch, nr, [6]uint64{2: arg}); got != wantRet {
+				t.Errorf("%s: arg %#x returns %#x, want %#x", op, arg, got, wantRet)
+			}
+			// 参数条件只对规则中的系统调用生效
+			if got := runSeccompFilter(t, filter, nativeArch, nr+1, [6]uint64{2: arg}); got != seccompRetAllow {
+				t.Errorf("%s: other syscall with arg %#x returns %#x, want allow", op, arg, got)
+			}
+		}
+	}
+}
+
+func TestCompileSeccompInvalidArgs(t *testing.T) {
+	skipUnsupportedSeccomp(t)
+	tests := []common.SeccompArg{
+		{Index: 6, Op: "SCMP_CMP_EQ"},
+		{Index: 0, Op: "SCMP_CMP_FOO"},
+	}
+	for _, arg := range tests {
+		profile := &common.Seccomp{
+			DefaultAction: "SCMP_ACT_ALLOW",
+			Syscalls: []common.SeccompSyscall{{
+				Names:  []string{"read"},
+				Action: "SCMP_ACT_ERRNO",
+				Args:   []common.SeccompArg{arg},
+			}},
+		}
+		if _, err := compileSeccomp(profile, nil); err == nil {
+			t.Errorf("compileSeccomp() with arg %+v succeeds, want error", arg)
+		}
+	}
+}
+
+func TestCompileSeccompManyNames(t *testing.T) {
+	skipUnsupportedSeccomp(t)
+	var names []string
+	for name := range syscallNumbers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 保留一个系统调用不在规则中，用于验证未命中的系统调用执行默认动作
+	allowed := names[len(names)-1]
+	names = names[:len(names)-1]
+	if len(names) <= 128 {
+		t.Fatalf("need more than 128 syscalls, got %d", len(names))
+	}
+
+	profile := &common.Seccomp{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls: []common.SeccompSyscall{
+			{Names: names, Action: "SCMP_ACT_ERRNO"},
+			{Names: []string{allowed}, Action: "SCMP_ACT_KILL_PROCESS"},
+		},
+	}
+	filter, err := compileSeccomp(profile, nil)
+	if err != nil {
+		t.Fatalf("compileSeccomp() error = %v", err)
+	}
+	for _, name := range names {
+		if got := runSeccompFilter(t, filter, nativeArch, syscallNumbers[name], [6]uint64{}); got != seccompRetErrno|uint32(unix.EPERM) {
+			t.Errorf("syscall %s returns %#x, want errno", name, got)
+		}
+	}
+	// 后续规则在跨越多批比较后仍然生效
+	if got := runSeccompFilter(t, filter, nativeArch, syscallNumbers[allowed], [6]uint64{}); got != seccompRetKillProcess {
+		t.Errorf("syscall %s returns %#x, want kill process", allowed, got)
+	}
+	if got := runSeccompFilter(t, filter, nativeArch, 0x3fffffff, [6]uint64{}); got != seccompRetAllow {
+		t.Errorf("unknown syscall returns %#x, want allow", got)
+	}
+}
+
+func TestCompileSeccompArch(t *testing.T) {
+	skipUnsupportedSeccomp(t)
+	profile := &common.Seccomp{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Architectures: []string{nativeArchName},
+		Syscalls:      []common.SeccompSyscall{{Names: []string{"read"}, Action: "SCMP_ACT_ERRNO"}},
+	}
+	filter, err := compileSeccomp(profile, nil)
+	if err != nil {
+		t.Fatalf("compileSeccomp() error = %v", err)
+	}
+	tests := []struct {
+		arch uint32
+		nr   uint32
+		want uint32
+	}{
+		{arch: nativeArch, nr: syscallNumbers["write"], want: seccompRetAllow},
+		{arch: nativeArch, nr: syscallNumbers["read"], want: seccompRetErrno | uint32(unix.EPERM)},
+		// 其他架构的系统调用
+		{arch: 0x40000003, nr: syscallNumbers["write"], want: seccompRetKillProcess},
+		// x32 ABI的系统调用
+		{arch: nativeArch, nr: seccompX32SyscallBit | syscallNumbers["read"], want: seccompRetKillProcess},
+	}
+	for _, tt := range tests {
+		if got := runSeccompFilter(t, filter, tt.arch, tt.nr, [6]uint64{}); got != tt.want {
+			t.Errorf("arch %#x nr %#x returns %#x, want %#x", tt.arch, tt.nr, got, tt.want)
+		}
+	}
+
+	for _, p := range []*common.Seccomp{
+		{DefaultAction: "SCMP_ACT_ALLOW", Architectures: []string{"SCMP_ARCH_PPC64LE"}},
+		{DefaultAction: "SCMP_ACT_ALLOW", ArchMap: []common.SeccompArch{{Arch: "SCMP_ARCH_S390X"}}},
+	} {
+		if _, err := compileSeccomp(p, nil); err == nil {
+			t.Errorf("compileSeccomp() for %+v succeeds, want error", p)
+		}
+	}
+}
+
+func TestCompileSeccompDefaultProfile(t *testing.T) {
+	skipUnsupportedSeccomp(t)
+	filter, err := compileSeccomp(defaultSeccompProfile, defaultCapabilities)
+	if err != nil {
+		t.Fatalf("compileSeccomp() error = %v", err)
+	}
+	for _, name := range []string{"read", "write", "clone"} {
+		if nr, ok := syscallNumbers[name]; ok {
+			if got := runSeccompFilter(t, filter, nativeArch, nr, [6]uint64{}); got != seccompRetAllow {
+				t.Errorf("syscall %s returns %#x, want allow", name, got)
+			}
+		}
+	}
+	for _, name := range []string{"reboot", "kexec_load", "mount"} {
+		if got := runSeccompFilter(t, filter, nativeArch, syscallNumbers[name], [6]uint64{}); got&0xffff0000 != seccompRetErrno {
+			t.Errorf("syscall %s returns %#x, want errno", name, got)
+		}
+	}
+}
+
+func TestCompileSeccompUnsupportedArch(t *testing.T) {
+	if seccompSupported {
+		t.Skip("seccomp is supported on this architecture")
+	}
+	if _, err := compileSeccomp(defaultSeccompProfile, nil); err == nil {
+		t.Error("compileSeccomp() succeeds on an unsupported architecture, want error")
+	}
+	if profile, err := resolveSeccompProfile(&common.RunParam{}); err != nil || profile != nil {
+		t.Errorf("resolveSeccompProfile() = %v, %v, want no default profile", profile, err)
+	}
+}
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// NoNewPrivileges 禁止容器内的进程通过setuid等方式获得更高的权限
+const NoNewPrivileges = "no-new-privileges"
+
+// ParseSecurityOpts 解析--security-opt，支持seccomp=<profile.json>|unconfined及no-new-privileges[=true|false]。
+// 返回的seccomp配置为空字符串时使用默认配置，为unconfined时不启用，否则为从文件中读取并校验后的配置内容，
+// 容器重启时不再依赖该文件
+func ParseSecurityOpts(opts []string) (string, bool, error) {
+	var (
+		seccompProfile  string
+		noNewPrivileges bool
+	)
+	for _, opt := range opts {
+		kv := strings.SplitN(opt, "=", 2)
+		if kv[0] == NoNewPrivileges {
+			if len(kv) == 1 {
+				noNewPrivileges = true
+				continue
+			}
+			value, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return "", false, errors.Errorf("invalid --security-opt: %s", opt)
+			}
+			noNewPrivileges = value
+			continue
+		}
+		if len(kv) != 2 || kv[0] != "seccomp" || kv[1] == "" {
+			return "", false, errors.Errorf("invalid --security-opt: %s", opt)
+		}
+		if kv[1] == SeccompUnconfined {
+			seccompProfile = SeccompUnconfined
+			continue
+		}
+
+		contentBytes, err := ioutil.ReadFile(kv[1])
+		if err != nil {
+			return "", false, errors.Wrapf(err, "read seccomp profile %s", kv[1])
+		}
+		profile := new(common.Seccomp)
+		if err = json.Unmarshal(contentBytes, profile); err != nil {
+			return "", false, errors.Wrapf(err, "decode seccomp profile %s", kv[1])
+		}
+		if _, err = compileSeccomp(profile, nil); err != nil {
+			return "", false, errors.Wrapf(err, "invalid seccomp profile %s", kv[1])
+		}
+		compactBytes, err := json.Marshal(profile)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "encode seccomp profile %s", kv[1])
+		}
+		seccompProfile = string(compactBytes)
+	}
+	return seccompProfile, noNewPrivileges, nil
+}
+
+// initProcessStatus 读取容器1号进程/proc/1/status中的字段，需在加入容器的命名空间后调用
+func initProcessStatus(key string) (string, error) {
+	return processStatus("1", key)
+}
+
+// processStatus 读取/proc/<pid>/status中的字段
+func processStatus(pid, key string) (string, error) {
+	statusPath := "/proc/" + pid + "/status"
+	file, err := os.Open(statusPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "open %s", statusPath)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, key+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, key+":")), nil
+		}
+	}
+	return "", errors.Errorf("%s not found in %s", key, statusPath)
+}
+
+// setNoNewPrivileges 设置no_new_privs，之后执行的setuid程序及带有file capability的程序无法获得更高的权限
+func setNoNewPrivileges() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return errors.Wrap(err, "set no_new_privs")
+	}
+	return nil
+}
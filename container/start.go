@@ -0,0 +1,77 @@
+package container
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// Start 启动已创建的容器；对于已停止的容器，复用原有的可写层、IP地址及cgroup，并重新执行容器的原始命令
+func Start(containerName string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status == common.Created {
+		return sendStartSignal(containerName)
+	}
+	if containerInfo.Status != common.Stop && containerInfo.Status != common.Exit {
+		return errors.Errorf("container %s is %s", containerName, containerInfo.Status)
+	}
+	// 原监控进程记录退出信息后仍在运行时说明容器尚未完全停止
+	if !waitProcessExit(containerInfo.MonitorPid, time.Second) {
+		return errors.Errorf("container %s is still stopping", containerName)
+	}
+
+	return startMonitor(&monitorParam{ContainerName: containerName})
+}
+
+// Restart 停止运行中的容器，待其退出后重新启动
+func Restart(containerName string, timeout time.Duration) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+
+	switch containerInfo.Status {
+	case common.Running, common.Restarting, common.Paused:
+		if err = Stop(containerName, timeout); err != nil {
+			return err
+		}
+	}
+
+	return Start(containerName)
+}
+
+// resumeContainer 重新挂载已停止容器的workspace并启动容器进程
+func resumeContainer(containerName string, hub *ioHub) (*common.BaseConfig, *exec.Cmd, error) {
+	unlock, err := lockContainerConfig(containerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if err = ensureWorkspace(containerName, containerInfo.Volume, containerInfo.Spec.ReadOnly); err != nil {
+		return nil, nil, errors.Wrap(err, "ensure workspace")
+	}
+
+	subprocess, err := launchContainer(containerInfo, hub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return containerInfo, subprocess, nil
+}
@@ -0,0 +1,209 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/liruonian/basin/cgroup"
+	"github.com/liruonian/basin/cgroup/subsystem"
+	"github.com/liruonian/basin/common"
+	"github.com/liruonian/basin/network"
+	"github.com/pkg/errors"
+)
+
+const (
+	// statsInterval basin stats刷新的间隔
+	statsInterval = time.Second
+	// unavailableStat 无法从cgroup中读取的资源使用情况在表格中的展示
+	unavailableStat = "--"
+)
+
+// ContainerStats 容器的资源使用情况
+type ContainerStats struct {
+	Id      string           `json:"id"`
+	Name    string           `json:"name"`
+	Read    time.Time        `json:"read"`
+	Cgroup  *subsystem.Stats `json:"cgroup"`
+	Network NetworkStats     `json:"network"`
+}
+
+type NetworkStats struct {
+	RxBytes uint64 `json:"rxBytes"`
+	TxBytes uint64 `json:"txBytes"`
+}
+
+// Stats 展示容器的资源使用情况，未指定容器时展示全部运行中的容器。
+// noStream为true时以JSON格式输出一次，否则每秒刷新一次表格
+func Stats(containerNames []string, noStream bool) error {
+	if noStream {
+		stats, err := collectStats(containerNames)
+		if err != nil {
+			return err
+		}
+		jsonBytes, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "marshal container stats")
+		}
+		fmt.Println(string(jsonBytes))
+		return nil
+	}
+
+	previous := make(map[string]*ContainerStats)
+	for {
+		stats, err := collectStats(containerNames)
+		if err != nil {
+			return err
+		}
+		// 清屏后从左上角重新输出
+		fmt.Print("\033[2J\033[H")
+		if err = printStats(stats, previous); err != nil {
+			return err
+		}
+
+		previous = make(map[string]*ContainerStats, len(stats))
+		for _, item := range stats {
+			previous[item.Id] = item
+		}
+		time.Sleep(statsInterval)
+	}
+}
+
+// collectStats 读取容器cgroup及veth中的资源使用情况
+func collectStats(containerNames []string) ([]*ContainerStats, error) {
+	var containers []*common.BaseConfig
+	if len(containerNames) == 0 {
+		infos, err := listContainerInfos()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.Status == common.Running || info.Status == common.Paused {
+				containers = append(containers, info)
+			}
+		}
+	} else {
+		for _, containerRef := range containerNames {
+			containerName, err := resolveContainerName(containerRef)
+			if err != nil {
+				return nil, err
+			}
+			info, err := getContainerInfoByName(containerName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "get container %s info", containerName)
+			}
+			if info.Status != common.Running && info.Status != common.Paused {
+				return nil, errors.Errorf("container %s is not running", containerName)
+			}
+			containers = append(containers, info)
+		}
+	}
+
+	stats := make([]*ContainerStats, 0, len(containers))
+	for _, info := range containers {
+		cgroupStats, err := cgroup.NewCgroupManager(info.CgroupPath).GetStats()
+		if err != nil {
+			return nil, errors.Wrapf(err, "get container %s cgroup stats", info.Name)
+		}
+		rxBytes, txBytes, err := network.EndpointStats(info)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get container %s network stats", info.Name)
+		}
+		stats = append(stats, &ContainerStats{
+			Id:      info.Id,
+			Name:    info.Name,
+			Read:    time.Now(),
+			Cgroup:  cgroupStats,
+			Network: NetworkStats{RxBytes: rxBytes, TxBytes: txBytes},
+		})
+	}
+	return stats, nil
+}
+
+// printStats 以表格形式输出资源使用情况，cpu使用率根据与上一次采样的差值计算，无法读取的部分输出为--
+func printStats(stats []*ContainerStats, previous map[string]*ContainerStats) error {
+	hostMemory := hostMemoryTotal()
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	_, _ = fmt.Fprint(w, "ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, item := range stats {
+		cpuPercent := unavailableStat
+		if cpu := item.Cgroup.Cpu; cpu != nil {
+			percent := 0.0
+			if prev, ok := previous[item.Id]; ok && prev.Cgroup.Cpu != nil && cpu.UsageNanos >= prev.Cgroup.Cpu.UsageNanos {
+				elapsed := item.Read.Sub(prev.Read).Nanoseconds()
+				if elapsed > 0 {
+					percent = float64(cpu.UsageNanos-prev.Cgroup.Cpu.UsageNanos) / float64(elapsed) * 100
+				}
+			}
+			cpuPercent = fmt.Sprintf("%.2f%%", percent)
+		}
+
+		memUsage, memPercent := unavailableStat+" / "+unavailableStat, unavailableStat
+		if memory := item.Cgroup.Memory; memory != nil {
+			// 与docker一致，内存使用量不包含页缓存；未限制内存时以宿主机内存作为上限
+			usage := memory.Usage
+			if usage > memory.Cache {
+				usage -= memory.Cache
+			}
+			limit := memory.Limit
+			if limit == 0 || (hostMemory > 0 && limit > hostMemory) {
+				limit = hostMemory
+			}
+			percent := 0.0
+			if limit > 0 {
+				percent = float64(usage) / float64(limit) * 100
+			}
+			memUsage = formatBytes(usage) + " / " + formatBytes(limit)
+			memPercent = fmt.Sprintf("%.2f%%", percent)
+		}
+
+		blockIO := unavailableStat + " / " + unavailableStat
+		if blkio := item.Cgroup.Blkio; blkio != nil {
+			blockIO = formatBytes(blkio.ReadBytes) + " / " + formatBytes(blkio.WriteBytes)
+		}
+		pids := unavailableStat
+		if item.Cgroup.Pids != nil {
+			pids = strconv.FormatUint(item.Cgroup.Pids.Current, 10)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s / %s\t%s\t%s\n",
+			shortId(item.Id),
+			item.Name,
+			cpuPercent,
+			memUsage,
+			memPercent,
+			formatBytes(item.Network.RxBytes), formatBytes(item.Network.TxBytes),
+			blockIO,
+			pids)
+	}
+	return w.Flush()
+}
+
+// hostMemoryTotal 返回宿主机的内存总量
+func hostMemoryTotal() uint64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return uint64(info.Totalram) * uint64(info.Unit)
+}
+
+// formatBytes 以1024为进制格式化字节数
+func formatBytes(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", size, units[unit])
+	}
+	return fmt.Sprintf("%.2f%s", value, units[unit])
+}
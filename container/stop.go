@@ -1,44 +1,231 @@
 package container
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/liruonian/basin/cgroup"
 	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
-func Stop(containerName string) {
+// DefaultStopTimeout 停止容器时等待容器进程退出的默认超时时间，超时后强制杀死容器进程
+const DefaultStopTimeout = 10 * time.Second
+
+// killTimeout 强制杀死容器进程后等待其退出的超时时间，与停止超时无关，使-t 0也能等到进程退出
+const killTimeout = 5 * time.Second
+
+// monitorExitTimeout 容器进程退出后，等待监控进程记录退出信息的超时时间
+const monitorExitTimeout = 5 * time.Second
+
+// Stop 向容器发送停止信号并等待其退出，超时后强制杀死容器cgroup中的全部进程，
+// 容器进程退出后状态才会变为stopped
+func Stop(containerName string, timeout time.Duration) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	// 在配置文件锁内检查状态并记录手动停止的标记，监控进程据此将容器标记为stopped且不再按照重启策略重启
+	var pid int
+	stopped := false
+	containerInfo, err := updateContainerInfo(containerName, func(containerInfo *common.BaseConfig) error {
+		var err error
+		pid, stopped, err = markManuallyStopped(containerInfo)
+		return err
+	})
+	if err != nil || stopped {
+		return err
+	}
+
+	stopSignal, err := ParseSignal(containerInfo.Spec.StopSignal)
+	if err != nil {
+		return err
+	}
+	if err = syscall.Kill(pid, stopSignal); err != nil && err != syscall.ESRCH {
+		return errors.Wrapf(err, "send signal %s to container %s", unix.SignalName(stopSignal), containerName)
+	}
+	// 冻结的进程无法处理信号，发送信号后解冻使其退出
+	if containerInfo.Status == common.Paused {
+		if err = cgroup.NewCgroupManager(containerInfo.CgroupPath).Thaw(); err != nil {
+			return errors.Wrapf(err, "unpause container %s", containerName)
+		}
+	}
+
+	if !waitProcessExit(pid, timeout) {
+		if timeout > 0 {
+			logrus.Warnf("container %s did not stop in %v, killing it", containerName, timeout)
+		}
+		killContainerProcesses(containerInfo, pid)
+		if !waitProcessExit(pid, killTimeout) {
+			return errors.Errorf("container %s did not exit after being killed", containerName)
+		}
+		// init进程退出后cgroup中可能仍有进程尚未退出，全部退出后才将容器标记为stopped
+		if !waitCgroupEmpty(containerInfo, killTimeout) {
+			return errors.Errorf("processes of container %s did not exit after being killed", containerName)
+		}
+	}
+
+	// 等待监控进程记录退出信息，监控进程异常退出时由此处兜底
+	waitProcessExit(containerInfo.MonitorPid, monitorExitTimeout)
+	_, err = updateContainerInfo(containerName, func(containerInfo *common.BaseConfig) error {
+		if containerInfo.Status == common.Running || containerInfo.Status == common.Created || containerInfo.Status == common.Paused {
+			containerInfo.Status = common.Stop
+			containerInfo.Pid = ""
+		}
+		return nil
+	})
+	// 前台运行的容器退出后配置已被清理
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	return nil
+}
+
+// markManuallyStopped 为容器记录手动停止的标记，返回需要停止的容器进程pid；容器已没有运行中的进程时stopped为true
+func markManuallyStopped(containerInfo *common.BaseConfig) (pid int, stopped bool, err error) {
+	switch containerInfo.Status {
+	case common.Restarting:
+		// 等待重启的容器没有运行中的进程，标记为stopped后监控进程不会再重启它
+		containerInfo.Status = common.Stop
+		stopped = true
+	case common.Running, common.Created, common.Paused:
+		if pid, err = strconv.Atoi(containerInfo.Pid); err != nil {
+			return 0, false, errors.Wrapf(err, "convert pid %s", containerInfo.Pid)
+		}
+	case common.Stop, common.Exit:
+		return 0, true, nil
+	default:
+		return 0, false, errors.Errorf("container %s is not running", containerInfo.Name)
+	}
+	containerInfo.ManuallyStopped = true
+	return pid, stopped, nil
+}
+
+// Kill 向容器的init进程发送指定信号
+func Kill(containerName, signal string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
 	containerInfo, err := getContainerInfoByName(containerName)
 	if err != nil {
-		logrus.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status == common.Paused {
+		return errors.Errorf("container %s is paused, unpause it before killing", containerName)
+	}
+	if containerInfo.Status != common.Running {
+		return errors.Errorf("container %s is not running", containerName)
 	}
-	pidInt, err := strconv.Atoi(containerInfo.Pid)
+
+	pid, err := strconv.Atoi(containerInfo.Pid)
 	if err != nil {
-		logrus.Errorf("Conver pid from string to int error %v", err)
-		return
+		return errors.Wrapf(err, "convert pid %s", containerInfo.Pid)
+	}
+	sig, err := ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	if err = syscall.Kill(pid, sig); err != nil {
+		return errors.Wrapf(err, "send signal %s to container %s", unix.SignalName(sig), containerName)
+	}
+	return nil
+}
+
+// ParseSignal 解析信号，支持SIGKILL、KILL及9等格式，默认为SIGTERM
+func ParseSignal(signal string) (syscall.Signal, error) {
+	if signal == "" {
+		return syscall.SIGTERM, nil
+	}
+	if num, err := strconv.Atoi(signal); err == nil {
+		if num <= 0 || unix.SignalName(syscall.Signal(num)) == "" {
+			return 0, errors.Errorf("invalid signal: %s", signal)
+		}
+		return syscall.Signal(num), nil
 	}
 
-	if err = syscall.Kill(pidInt, syscall.SIGTERM); err != nil {
-		logrus.Errorf("Stop container %s error %v", containerName, err)
-		return
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, errors.Errorf("invalid signal: %s", signal)
 	}
+	return sig, nil
+}
 
-	containerInfo.Status = common.Stop
-	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
+// killContainerProcesses 强制杀死容器cgroup中的全部进程
+func killContainerProcesses(containerInfo *common.BaseConfig, pid int) {
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+
+	pids, err := cgroup.NewCgroupManager(containerInfo.CgroupPath).Pids()
 	if err != nil {
-		logrus.Errorf("Json marshal %s error %v", containerName, err)
+		logrus.Errorf("get container %s pids error %v", containerInfo.Name, err)
 		return
 	}
+	for _, p := range pids {
+		if err = syscall.Kill(p, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			logrus.Errorf("kill process %d error %v", p, err)
+		}
+	}
+}
+
+// waitCgroupEmpty 轮询等待容器cgroup中的进程全部退出，返回是否已在超时前退出
+func waitCgroupEmpty(containerInfo *common.BaseConfig, timeout time.Duration) bool {
+	cgroupManager := cgroup.NewCgroupManager(containerInfo.CgroupPath)
+	deadline := time.Now().Add(timeout)
+	for {
+		pids, err := cgroupManager.Pids()
+		if err != nil {
+			logrus.Errorf("get container %s pids error %v", containerInfo.Name, err)
+			return true
+		}
+		if len(pids) == 0 {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// waitProcessExit 轮询等待进程退出，返回进程是否已在超时前退出
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	if pid <= 0 {
+		return true
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if !processAlive(pid) {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
 
-	dirURL := fmt.Sprintf(common.ContainerDataUrlFormat, containerName)
-	configFilePath := dirURL + common.ConfigFileName
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, common.Perm0622); err != nil {
-		logrus.Errorf("Write file %s error:%v", configFilePath, err)
+// processAlive 判断进程是否存活，已退出但尚未被回收的僵尸进程视为不存活
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+		return false
+	}
+	contentBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
 	}
+	// stat的格式为`pid (comm) state ...`，comm中可能包含空格，因此从最后一个')'之后解析
+	stat := string(contentBytes)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	return len(fields) > 0 && fields[0] != "Z"
 }
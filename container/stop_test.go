@@ -0,0 +1,36 @@
+package container
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		signal  string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{signal: "", want: syscall.SIGTERM},
+		{signal: "SIGKILL", want: syscall.SIGKILL},
+		{signal: "KILL", want: syscall.SIGKILL},
+		{signal: "kill", want: syscall.SIGKILL},
+		{signal: "sigusr1", want: syscall.SIGUSR1},
+		{signal: "9", want: syscall.SIGKILL},
+		{signal: "15", want: syscall.SIGTERM},
+		{signal: "0", wantErr: true},
+		{signal: "-1", wantErr: true},
+		{signal: "100", wantErr: true},
+		{signal: "SIGFOO", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSignal(tt.signal)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSignal(%q) error = %v, wantErr %v", tt.signal, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSignal(%q) = %v, want %v", tt.signal, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package container
+
+import (
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// UnmaskAll 取消全部默认屏蔽及只读的路径
+const UnmaskAll = "ALL"
+
+// defaultMaskedPaths 默认屏蔽的路径，这些路径会泄露宿主机信息或允许容器影响宿主机
+var defaultMaskedPaths = []string{
+	"/proc/asound",
+	"/proc/acpi",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/sys/firmware",
+	"/sys/devices/virtual/powercap",
+}
+
+// defaultReadonlyPaths 默认只读的路径，避免容器修改宿主机的内核参数
+var defaultReadonlyPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// ResolveSystemPaths 在默认列表的基础上追加mask及readonly中的路径，并移除unmask中的路径，
+// unmask中包含ALL时移除全部默认路径，unmask中的其它路径必须在默认列表中。返回容器最终屏蔽及只读的路径
+func ResolveSystemPaths(mask, unmask, readonly []string) ([]string, []string, error) {
+	for _, paths := range [][]string{mask, readonly} {
+		for _, p := range paths {
+			if !path.IsAbs(p) {
+				return nil, nil, errors.Errorf("invalid path %s, it needs to be an absolute path", p)
+			}
+		}
+	}
+
+	unmasked := make(map[string]bool, len(unmask))
+	for _, p := range unmask {
+		if p == UnmaskAll {
+			unmasked[p] = true
+			continue
+		}
+		if !path.IsAbs(p) {
+			return nil, nil, errors.Errorf("invalid path %s, it needs to be an absolute path", p)
+		}
+		p = path.Clean(p)
+		if !isDefaultSystemPath(p) {
+			return nil, nil, errors.Errorf("invalid unmask path %s, it is neither masked nor read-only by default", p)
+		}
+		unmasked[p] = true
+	}
+	filter := func(defaults, extra []string) []string {
+		var result []string
+		if !unmasked[UnmaskAll] {
+			for _, p := range defaults {
+				if !unmasked[p] {
+					result = append(result, p)
+				}
+			}
+		}
+		for _, p := range extra {
+			result = append(result, path.Clean(p))
+		}
+		return result
+	}
+	return filter(defaultMaskedPaths, mask), filter(defaultReadonlyPaths, readonly), nil
+}
+
+// isDefaultSystemPath 判断路径是否在默认屏蔽或只读的列表中
+func isDefaultSystemPath(p string) bool {
+	for _, paths := range [][]string{defaultMaskedPaths, defaultReadonlyPaths} {
+		for _, d := range paths {
+			if d == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setupSystemPaths 挂载sysfs，并屏蔽maskedPaths、将readonlyPaths重新挂载为只读，
+// 需在/proc及/dev挂载完成后调用。不存在的路径会被忽略。sysfs仅在特权容器中可写
+func setupSystemPaths(maskedPaths, readonlyPaths []string, privileged bool) error {
+	if err := os.MkdirAll("/sys", 0755); err != nil {
+		return errors.Wrap(err, "mkdir /sys")
+	}
+	flags := uintptr(syscall.MS_NOSUID | syscall.MS_NOEXEC | syscall.MS_NODEV)
+	if !privileged {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount("sysfs", "/sys", "sysfs", flags, ""); err != nil {
+		return errors.Wrap(err, "mount sysfs")
+	}
+
+	for _, p := range readonlyPaths {
+		if err := readonlyPath(p); err != nil {
+			return err
+		}
+	}
+	for _, p := range maskedPaths {
+		if err := maskPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maskPath 目录以只读的空tmpfs覆盖，文件以/dev/null覆盖
+func maskPath(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "stat %s", p)
+	}
+	if info.IsDir() {
+		err = syscall.Mount("tmpfs", p, "tmpfs", syscall.MS_RDONLY, "")
+	} else {
+		err = syscall.Mount("/dev/null", p, "", syscall.MS_BIND, "")
+	}
+	if err != nil {
+		return errors.Wrapf(err, "mask %s", p)
+	}
+	return nil
+}
+
+// readonlyPath 将路径绑定挂载到自身后重新挂载为只读
+func readonlyPath(p string) error {
+	if err := syscall.Mount(p, p, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "bind %s", p)
+	}
+	if err := syscall.Mount(p, p, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_REC, ""); err != nil {
+		return errors.Wrapf(err, "remount %s read-only", p)
+	}
+	return nil
+}
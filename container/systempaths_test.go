@@ -0,0 +1,66 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveSystemPaths(t *testing.T) {
+	tests := []struct {
+		name         string
+		mask         []string
+		unmask       []string
+		readonly     []string
+		wantMasked   []string
+		wantReadonly []string
+		wantErr      bool
+	}{
+		{name: "defaults", wantMasked: defaultMaskedPaths, wantReadonly: defaultReadonlyPaths},
+		{
+			name:         "extra paths",
+			mask:         []string{"/etc/hosts/"},
+			readonly:     []string{"/etc"},
+			wantMasked:   append(append([]string{}, defaultMaskedPaths...), "/etc/hosts"),
+			wantReadonly: append(append([]string{}, defaultReadonlyPaths...), "/etc"),
+		},
+		{
+			name:         "unmask default paths",
+			unmask:       []string{"/proc/kcore", "/proc/sys/"},
+			wantMasked:   without(defaultMaskedPaths, "/proc/kcore"),
+			wantReadonly: without(defaultReadonlyPaths, "/proc/sys"),
+		},
+		{
+			name:       "unmask all",
+			mask:       []string{"/etc/hosts"},
+			unmask:     []string{UnmaskAll},
+			wantMasked: []string{"/etc/hosts"},
+		},
+		{name: "relative mask", mask: []string{"etc/hosts"}, wantErr: true},
+		{name: "relative readonly", readonly: []string{"etc"}, wantErr: true},
+		{name: "relative unmask", unmask: []string{"proc/kcore"}, wantErr: true},
+		{name: "unknown unmask", unmask: []string{"/proc/kmsg"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		masked, readonly, err := ResolveSystemPaths(tt.mask, tt.unmask, tt.readonly)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ResolveSystemPaths() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(masked, tt.wantMasked) {
+			t.Errorf("%s: masked = %v, want %v", tt.name, masked, tt.wantMasked)
+		}
+		if !reflect.DeepEqual(readonly, tt.wantReadonly) {
+			t.Errorf("%s: readonly = %v, want %v", tt.name, readonly, tt.wantReadonly)
+		}
+	}
+}
+
+func without(paths []string, p string) []string {
+	var result []string
+	for _, s := range paths {
+		if s != p {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/liruonian/basin/cgroup"
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// DefaultTopColumns basin top默认展示的列
+const DefaultTopColumns = "pid,cpid,user,time,cmd"
+
+// clockTicks /proc/<pid>/stat中cpu时间的单位，linux上USER_HZ固定为100
+const clockTicks = 100
+
+// topProcess 从/proc中读取的容器进程信息
+type topProcess struct {
+	Pid   int
+	NsPid int
+	PPid  int
+	Uid   int
+	User  string
+	State string
+	Ticks uint64
+	Comm  string
+	Args  string
+}
+
+type topColumn struct {
+	Header string
+	Value  func(p *topProcess) string
+}
+
+// topColumns 支持的列，名称与ps保持一致，cpid为进程在容器pid命名空间中的进程号
+var topColumns = map[string]topColumn{
+	"pid":  {"PID", func(p *topProcess) string { return strconv.Itoa(p.Pid) }},
+	"cpid": {"CPID", func(p *topProcess) string { return strconv.Itoa(p.NsPid) }},
+	"ppid": {"PPID", func(p *topProcess) string { return strconv.Itoa(p.PPid) }},
+	"uid":  {"UID", func(p *topProcess) string { return strconv.Itoa(p.Uid) }},
+	"user": {"USER", func(p *topProcess) string { return p.User }},
+	"stat": {"STAT", func(p *topProcess) string { return p.State }},
+	"time": {"TIME", func(p *topProcess) string { return formatCpuTime(p.Ticks) }},
+	"comm": {"COMM", func(p *topProcess) string { return p.Comm }},
+	"cmd":  {"COMMAND", func(p *topProcess) string { return p.Args }},
+	"args": {"COMMAND", func(p *topProcess) string { return p.Args }},
+}
+
+// Top 列出容器cgroup中的全部进程，columns为逗号分隔的列名
+func Top(containerName, columns string) error {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return errors.Wrapf(err, "get container %s info", containerName)
+	}
+	if containerInfo.Status != common.Running && containerInfo.Status != common.Paused {
+		return errors.Errorf("container %s is not running", containerName)
+	}
+
+	var selected []topColumn
+	for _, name := range strings.Split(columns, ",") {
+		column, ok := topColumns[strings.TrimSpace(name)]
+		if !ok {
+			return errors.Errorf("unknown column %q", name)
+		}
+		selected = append(selected, column)
+	}
+
+	pids, err := cgroup.NewCgroupManager(containerInfo.CgroupPath).Pids()
+	if err != nil {
+		return errors.Wrapf(err, "get container %s pids", containerName)
+	}
+	// 用户名以容器内的/etc/passwd为准
+	userNames := make(map[int]string)
+	passwdPath := path.Join(fmt.Sprintf(common.MergedDirFormat, containerName), "etc/passwd")
+	if users, err := parsePasswd(passwdPath); err == nil {
+		for _, user := range users {
+			if _, ok := userNames[user.Uid]; !ok {
+				userNames[user.Uid] = user.Name
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+	headers := make([]string, 0, len(selected))
+	for _, column := range selected {
+		headers = append(headers, column.Header)
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, pid := range pids {
+		process, err := readTopProcess(pid)
+		if err != nil {
+			// 进程可能在读取期间退出
+			continue
+		}
+		process.User = strconv.Itoa(process.Uid)
+		if name, ok := userNames[process.Uid]; ok {
+			process.User = name
+		}
+
+		values := make([]string, 0, len(selected))
+		for _, column := range selected {
+			values = append(values, column.Value(process))
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
+
+// readTopProcess 从/proc/<pid>/stat、status及cmdline中读取进程信息
+func readTopProcess(pid int) (*topProcess, error) {
+	procDir := fmt.Sprintf("/proc/%d", pid)
+	process := &topProcess{Pid: pid, NsPid: pid}
+
+	statBytes, err := ioutil.ReadFile(path.Join(procDir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// stat的格式为`pid (comm) state ppid ...`，comm中可能包含空格，因此以最后一个')'分隔
+	stat := string(statBytes)
+	commEnd := strings.LastIndex(stat, ")")
+	process.Comm = stat[strings.Index(stat, "(")+1 : commEnd]
+	fields := strings.Fields(stat[commEnd+1:])
+	if len(fields) < 13 {
+		return nil, errors.Errorf("invalid stat of pid %d", pid)
+	}
+	process.State = fields[0]
+	process.PPid, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	process.Ticks = utime + stime
+
+	statusBytes, err := ioutil.ReadFile(path.Join(procDir, "status"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(statusBytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			process.Uid, _ = strconv.Atoi(fields[1])
+		case "NSpid:":
+			// 最后一个值为进程在其所属最内层pid命名空间中的进程号
+			process.NsPid, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+
+	cmdlineBytes, err := ioutil.ReadFile(path.Join(procDir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	process.Args = strings.TrimSpace(strings.Join(strings.Split(string(cmdlineBytes), "\x00"), " "))
+	if process.Args == "" {
+		process.Args = "[" + process.Comm + "]"
+	}
+	return process, nil
+}
+
+// formatCpuTime 以ps的[DD-]HH:MM:SS格式展示cpu时间
+func formatCpuTime(ticks uint64) string {
+	seconds := ticks / clockTicks
+	days := seconds / 86400
+	timeStr := fmt.Sprintf("%02d:%02d:%02d", seconds/3600%24, seconds/60%60, seconds%60)
+	if days > 0 {
+		return fmt.Sprintf("%d-%s", days, timeStr)
+	}
+	return timeStr
+}
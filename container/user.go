@@ -0,0 +1,195 @@
+package container
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// passwdUser 容器/etc/passwd中的用户
+type passwdUser struct {
+	Name string
+	Uid  int
+	Gid  int
+	Home string
+}
+
+// parsePasswd 解析passwd文件，每行格式为 name:password:uid:gid:gecos:home:shell，忽略格式错误的行
+func parsePasswd(passwdPath string) ([]passwdUser, error) {
+	passwdFile, err := os.Open(passwdPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", passwdPath)
+	}
+	defer passwdFile.Close()
+
+	var users []passwdUser
+	scanner := bufio.NewScanner(passwdFile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+		users = append(users, passwdUser{Name: fields[0], Uid: uid, Gid: gid, Home: fields[5]})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read %s", passwdPath)
+	}
+	return users, nil
+}
+
+// groupEntry 容器/etc/group中的用户组
+type groupEntry struct {
+	Name    string
+	Gid     int
+	Members []string
+}
+
+// parseGroup 解析group文件，每行格式为 name:password:gid:member1,member2，忽略格式错误的行
+func parseGroup(groupPath string) ([]groupEntry, error) {
+	groupFile, err := os.Open(groupPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", groupPath)
+	}
+	defer groupFile.Close()
+
+	var groups []groupEntry
+	scanner := bufio.NewScanner(groupFile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		group := groupEntry{Name: fields[0], Gid: gid}
+		if fields[3] != "" {
+			group.Members = strings.Split(fields[3], ",")
+		}
+		groups = append(groups, group)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read %s", groupPath)
+	}
+	return groups, nil
+}
+
+// lookupUser 解析name|uid[:group|gid]形式的运行用户，用户名及组名以当前根目录下的/etc/passwd、/etc/group为准，
+// 因此需在切换到容器的根目录后调用。返回包含附加组的进程凭证及用户的主目录，不在passwd中的用户主目录为/，
+// 未指定用户时返回nil，即保持root
+func lookupUser(user string) (*syscall.Credential, string, error) {
+	if user == "" {
+		return nil, "", nil
+	}
+	// 镜像中可能没有passwd或group文件，此时仅支持数字形式的uid及gid
+	users, err := parsePasswd("/etc/passwd")
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, "", err
+	}
+	groups, err := parseGroup("/etc/group")
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, "", err
+	}
+
+	userPart, groupPart := user, ""
+	if index := strings.Index(user, ":"); index >= 0 {
+		userPart, groupPart = user[:index], user[index+1:]
+	}
+
+	// 用户不在passwd中时，uid必须为数字，gid默认为0
+	var matched *passwdUser
+	uid, err := strconv.Atoi(userPart)
+	for i := range users {
+		if (err == nil && users[i].Uid == uid) || (err != nil && users[i].Name == userPart) {
+			matched = &users[i]
+			break
+		}
+	}
+	if matched == nil && err != nil {
+		return nil, "", errors.Errorf("unable to find user %s: no matching entries in passwd file", userPart)
+	}
+	if uid < 0 {
+		return nil, "", errors.Errorf("invalid uid %d", uid)
+	}
+	credential := &syscall.Credential{Uid: uint32(uid), Groups: []uint32{}}
+	userName, home := "", "/"
+	if matched != nil {
+		credential.Uid = uint32(matched.Uid)
+		credential.Gid = uint32(matched.Gid)
+		userName, home = matched.Name, matched.Home
+	}
+
+	if groupPart != "" {
+		gid, err := strconv.Atoi(groupPart)
+		if err != nil {
+			gid = -1
+			for _, group := range groups {
+				if group.Name == groupPart {
+					gid = group.Gid
+					break
+				}
+			}
+			if gid < 0 {
+				return nil, "", errors.Errorf("unable to find group %s: no matching entries in group file", groupPart)
+			}
+		} else if gid < 0 {
+			return nil, "", errors.Errorf("invalid gid %d", gid)
+		}
+		credential.Gid = uint32(gid)
+	}
+
+	// 附加组为group文件中成员包含该用户的组
+	if userName != "" {
+		for _, group := range groups {
+			for _, member := range group.Members {
+				if member == userName && uint32(group.Gid) != credential.Gid {
+					credential.Groups = append(credential.Groups, uint32(group.Gid))
+					break
+				}
+			}
+		}
+	}
+	return credential, home, nil
+}
+
+// hasEnv 判断环境变量中是否已设置key
+func hasEnv(envs []string, key string) bool {
+	for _, env := range envs {
+		if strings.HasPrefix(env, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// removeEnv 移除环境变量中的key
+func removeEnv(envs []string, key string) []string {
+	result := make([]string, 0, len(envs))
+	for _, env := range envs {
+		if !strings.HasPrefix(env, key+"=") {
+			result = append(result, env)
+		}
+	}
+	return result
+}
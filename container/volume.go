@@ -2,32 +2,42 @@ package container
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/liruonian/basin/common"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-func NewWorkspace(containerName, imageName, volume string) error {
+func NewWorkspace(containerName, imageName, volume string, readonly bool) error {
 	// 创建lower层
 	err := createLower(containerName, imageName)
 	if err != nil {
 		return err
 	}
 
-	// 创建upper&work层
-	err = createUpperWork(containerName)
-	if err != nil {
-		return err
-	}
+	// 只读的容器不需要upper&work层，直接将lower层绑定挂载为容器的rootfs
+	if readonly {
+		if err = mountReadonlyRootfs(containerName); err != nil {
+			return err
+		}
+	} else {
+		// 创建upper&work层
+		err = createUpperWork(containerName)
+		if err != nil {
+			return err
+		}
 
-	// 通过overlayfs进行联合挂载
-	err = mountOverlayFS(containerName)
-	if err != nil {
-		logrus.Errorf("mount overlay fs err: %v", err)
+		// 通过overlayfs进行联合挂载
+		err = mountOverlayFS(containerName)
+		if err != nil {
+			logrus.Errorf("mount overlay fs err: %v", err)
+		}
 	}
 
 	// 如果指定了其他卷，则在此处处理挂载
@@ -44,6 +54,101 @@ func NewWorkspace(containerName, imageName, volume string) error {
 	return nil
 }
 
+// resolveVolume 解析数据卷参数，仅指定容器内路径时视为匿名卷，在VolumeUrl下创建以容器ID命名的宿主机目录。
+// 返回hostUrl:containerUrl形式的数据卷，以及匿名卷的宿主机目录
+func resolveVolume(containerId, volume string) (string, string) {
+	if volume == "" || strings.Contains(volume, ":") {
+		return volume, ""
+	}
+	hostUrl := common.VolumeUrl + containerId
+	return hostUrl + ":" + volume, hostUrl
+}
+
+// containerMounts 返回容器rootfs及数据卷的挂载信息
+func containerMounts(containerName, volume string, readonly bool) []common.Mount {
+	mounts := []common.Mount{
+		{
+			Type:        "overlay",
+			Source:      "overlay",
+			Destination: fmt.Sprintf(common.MergedDirFormat, containerName),
+			Options: fmt.Sprintf(common.OverlayFsFormat,
+				fmt.Sprintf(common.LowerDirFormat, containerName),
+				fmt.Sprintf(common.UpperDirFormat, containerName),
+				fmt.Sprintf(common.WorkDirFormat, containerName)),
+		},
+	}
+	if readonly {
+		mounts[0] = common.Mount{
+			Type:        "bind",
+			Source:      fmt.Sprintf(common.LowerDirFormat, containerName),
+			Destination: fmt.Sprintf(common.MergedDirFormat, containerName),
+			Options:     "bind,ro",
+		}
+	}
+	if volume != "" {
+		urls := strings.Split(volume, ":")
+		if len(urls) == 2 && urls[0] != "" && urls[1] != "" {
+			mounts = append(mounts, common.Mount{
+				Type:        "bind",
+				Source:      urls[0],
+				Destination: urls[1],
+				Options:     "bind",
+			})
+		}
+	}
+	return mounts
+}
+
+// ensureWorkspace 重新挂载已停止容器保留的overlayfs及数据卷，已挂载时不做处理
+func ensureWorkspace(containerName, volume string, readonly bool) error {
+	mergedUrl := fmt.Sprintf(common.MergedDirFormat, containerName)
+	mounted, err := isMountPoint(mergedUrl)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return nil
+	}
+
+	lowerUrl := fmt.Sprintf(common.LowerDirFormat, containerName)
+	if _, err = os.Stat(lowerUrl); err != nil {
+		return errors.Wrapf(err, "stat lower dir[%s]", lowerUrl)
+	}
+	if readonly {
+		err = mountReadonlyRootfs(containerName)
+	} else if err = createUpperWork(containerName); err == nil {
+		err = mountOverlayFS(containerName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if volume != "" {
+		urls := strings.Split(volume, ":")
+		if len(urls) == 2 && urls[0] != "" && urls[1] != "" {
+			return mountVolume(containerName, urls[0], urls[1])
+		}
+	}
+	return nil
+}
+
+// isMountPoint 通过/proc/self/mountinfo判断目录是否为挂载点，只读容器的rootfs为同一设备上的绑定挂载，无法通过比较设备判断
+func isMountPoint(dir string) (bool, error) {
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return false, errors.Wrap(err, "read /proc/self/mountinfo")
+	}
+	dir = filepath.Clean(dir)
+	for _, line := range strings.Split(string(data), "\n") {
+		// 第5列为挂载点
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == dir {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func deleteWorkSpace(containerName, volume string) error {
 	if volume != "" {
 		urls := strings.Split(volume, ":")
@@ -55,14 +160,14 @@ func deleteWorkSpace(containerName, volume string) error {
 		}
 	}
 
-	err := removeDirs(containerName)
+	err := umountOverlayFS(containerName)
 	if err != nil {
-		return errors.Wrap(err, "remove dirs")
+		return errors.Wrap(err, "umount overlayfs")
 	}
 
-	err = umountOverlayFS(containerName)
+	err = removeDirs(containerName)
 	if err != nil {
-		return errors.Wrap(err, "umount overlayfs")
+		return errors.Wrap(err, "remove dirs")
 	}
 
 	root := common.RootUrl + containerName
@@ -125,7 +230,7 @@ func mountOverlayFS(containerName string) error {
 }
 
 func mountVolume(containerName string, hostUrl, containerUrl string) error {
-	if err := os.Mkdir(hostUrl, common.Perm0777); err != nil && !os.IsExist(err) {
+	if err := os.MkdirAll(hostUrl, common.Perm0777); err != nil {
 		return errors.Wrapf(err, "mkdir host dir[%s] failed", hostUrl)
 	}
 
@@ -142,6 +247,19 @@ func mountVolume(containerName string, hostUrl, containerUrl string) error {
 	return nil
 }
 
+// mountReadonlyRootfs 将lower层绑定挂载到merged目录作为只读容器的rootfs，容器内会在初始化完成后将其重新挂载为只读
+func mountReadonlyRootfs(containerName string) error {
+	lowerUrl := fmt.Sprintf(common.LowerDirFormat, containerName)
+	mergedUrl := fmt.Sprintf(common.MergedDirFormat, containerName)
+	if err := os.MkdirAll(mergedUrl, common.Perm0777); err != nil {
+		return errors.Wrapf(err, "mkdir dir[%s] failed", mergedUrl)
+	}
+	if err := syscall.Mount(lowerUrl, mergedUrl, "", syscall.MS_BIND, ""); err != nil {
+		return errors.Wrapf(err, "bind mount %s to %s failed", lowerUrl, mergedUrl)
+	}
+	return nil
+}
+
 func umountVolume(containerName string, hostUrl, containerUrl string) error {
 	containerActualUrl := fmt.Sprintf(common.MergedDirFormat, containerName) + "/" + containerUrl
 	cmd := exec.Command("umount", containerActualUrl)
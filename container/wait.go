@@ -0,0 +1,37 @@
+package container
+
+import (
+	"time"
+
+	"github.com/liruonian/basin/common"
+	"github.com/pkg/errors"
+)
+
+// waitInterval 轮询容器状态的时间间隔
+const waitInterval = 100 * time.Millisecond
+
+// Wait 根据持久化的容器状态阻塞等待容器退出，返回容器的退出码。等待重启的容器视为仍在运行
+func Wait(containerName string) (int, error) {
+	containerName, err := resolveContainerName(containerName)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		containerInfo, err := getContainerInfoByName(containerName)
+		if err != nil {
+			return 0, errors.Wrapf(err, "get container %s info", containerName)
+		}
+
+		switch containerInfo.Status {
+		case common.Created, common.Running, common.Restarting, common.Paused:
+		default:
+			return containerInfo.ExitCode, nil
+		}
+
+		// 监控进程异常退出时容器状态不会再更新
+		if !processAlive(containerInfo.MonitorPid) {
+			return 0, errors.Errorf("monitor of container %s exited unexpectedly", containerName)
+		}
+		time.Sleep(waitInterval)
+	}
+}
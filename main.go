@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/liruonian/basin/common"
+	_ "github.com/liruonian/basin/nsenter"
 
 	"github.com/urfave/cli"
 )
@@ -15,11 +16,26 @@ func main() {
 
 	app.Commands = []cli.Command{
 		initCommand,
+		reaperCommand,
+		shimCommand,
 		runCmd,
+		createCommand,
 		listCommand,
 		logCommand,
 		stopCommand,
+		killCommand,
+		waitCommand,
+		startCommand,
+		restartCommand,
+		pauseCommand,
+		unpauseCommand,
 		removeCommand,
+		attachCommand,
+		execCommand,
+		inspectCommand,
+		topCommand,
+		statsCommand,
+		containerCommand,
 		networkCommand,
 	}
 
@@ -70,7 +70,21 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 	return nil
 }
 
+// Disconnect 删除宿主机一侧的veth设备，容器一侧的设备会随之删除；设备已随网络命名空间销毁时忽略
 func (d *BridgeNetworkDriver) Disconnect(network Network, endpoint *Endpoint) error {
+	if endpoint.Device.Name == "" {
+		return nil
+	}
+	link, err := netlink.LinkByName(endpoint.Device.Name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return errors.Wrapf(err, "get link %s", endpoint.Device.Name)
+	}
+	if err = netlink.LinkDel(link); err != nil {
+		return errors.Wrapf(err, "delete link %s", endpoint.Device.Name)
+	}
 	return nil
 }
 
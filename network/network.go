@@ -149,19 +149,105 @@ func Connect(networkName string, info *common.BaseConfig) error {
 		PortMapping: info.PortMapping,
 	}
 
-	if err = drivers[network.Driver].Connect(network, ep); err != nil {
+	if err = connectEndpoint(ep, info); err != nil {
 		return err
 	}
 
-	if err = configEndpointIpAddressAndRoute(ep, info); err != nil {
+	return configPortMapping(ep)
+}
+
+// Reconnect 将重启后的容器以原有的IP地址重新接入网络，端口映射规则在首次接入时已经配置
+func Reconnect(networkName string, info *common.BaseConfig) error {
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("no Such Network: %s", networkName)
+	}
+
+	ip := net.ParseIP(info.IPAddress).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid ip address: %s", info.IPAddress)
+	}
+
+	// 原容器的网络命名空间可能尚未完全销毁，先清理残留的veth设备
+	if info.Veth != "" {
+		if link, err := netlink.LinkByName(info.Veth); err == nil {
+			if err = netlink.LinkDel(link); err != nil {
+				return errors.Wrapf(err, "delete stale veth %s", info.Veth)
+			}
+		}
+	}
+
+	ep := &Endpoint{
+		Id:          fmt.Sprintf("%s-%s", info.Id, networkName),
+		IPAddress:   ip,
+		Network:     network,
+		PortMapping: info.PortMapping,
+	}
+
+	return connectEndpoint(ep, info)
+}
+
+func connectEndpoint(ep *Endpoint, info *common.BaseConfig) error {
+	if err := drivers[ep.Network.Driver].Connect(ep.Network, ep); err != nil {
 		return err
 	}
 
-	return configPortMapping(ep)
+	if err := configEndpointIpAddressAndRoute(ep, info); err != nil {
+		return err
+	}
+
+	info.IPAddress = ep.IPAddress.String()
+	info.Veth = ep.Device.Name
+	info.PeerVeth = ep.Device.PeerName
+	return nil
 }
 
+// Disconnect 将容器从网络中移除，删除veth设备及端口映射规则，并释放容器的IP地址
 func Disconnect(networkName string, info *common.BaseConfig) error {
-	return nil
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("no Such Network: %s", networkName)
+	}
+
+	ip := net.ParseIP(info.IPAddress).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid ip address: %s", info.IPAddress)
+	}
+
+	ep := &Endpoint{
+		Id:          fmt.Sprintf("%s-%s", info.Id, networkName),
+		IPAddress:   ip,
+		Network:     network,
+		PortMapping: info.PortMapping,
+	}
+	ep.Device.Name = info.Veth
+	ep.Device.PeerName = info.PeerVeth
+
+	if err := drivers[network.Driver].Disconnect(*network, ep); err != nil {
+		return err
+	}
+	removePortMapping(ep)
+
+	// Release会修改传入的IP，传入副本
+	releaseIP := make(net.IP, len(ip))
+	copy(releaseIP, ip)
+	return ipAllocator.Release(network.IPRange, &releaseIP)
+}
+
+// EndpointStats 返回容器网络的接收及发送字节数，宿主机一侧veth的发送即为容器的接收
+func EndpointStats(info *common.BaseConfig) (rxBytes uint64, txBytes uint64, err error) {
+	if info.Veth == "" {
+		return 0, 0, nil
+	}
+	link, err := netlink.LinkByName(info.Veth)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "get link %s", info.Veth)
+	}
+	statistics := link.Attrs().Statistics
+	if statistics == nil {
+		return 0, 0, nil
+	}
+	return statistics.TxBytes, statistics.RxBytes, nil
 }
 
 func (nw *Network) load(dumpPath string) error {
@@ -220,6 +306,15 @@ func configEndpointIpAddressAndRoute(ep *Endpoint, info *common.BaseConfig) erro
 }
 
 func configPortMapping(ep *Endpoint) error {
+	return iptablesPortMapping(ep, "-A")
+}
+
+// removePortMapping 删除容器接入网络时添加的端口映射规则
+func removePortMapping(ep *Endpoint) {
+	_ = iptablesPortMapping(ep, "-D")
+}
+
+func iptablesPortMapping(ep *Endpoint, action string) error {
 	var err error
 	for _, pm := range ep.PortMapping {
 		portMapping := strings.Split(pm, ":")
@@ -227,8 +322,8 @@ func configPortMapping(ep *Endpoint) error {
 			logrus.Errorf("port mapping format error, %v", pm)
 			continue
 		}
-		iptablesCmd := fmt.Sprintf("-t nat -A PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
-			portMapping[0], ep.IPAddress.String(), portMapping[1])
+		iptablesCmd := fmt.Sprintf("-t nat %s PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+			action, portMapping[0], ep.IPAddress.String(), portMapping[1])
 		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
 
 		output, err := cmd.Output()
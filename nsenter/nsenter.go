@@ -0,0 +1,68 @@
+package nsenter
+
+/*
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <sched.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+// 在Go运行时启动前执行，此时进程仍是单线程，可以安全地调用setns加入mnt等命名空间
+__attribute__((constructor)) void enter_namespace(void) {
+	char *basin_pid = getenv("basin_pid");
+	if (!basin_pid) {
+		return;
+	}
+
+	char *namespaces[] = { "ipc", "uts", "net", "pid", "mnt" };
+	int count = sizeof(namespaces) / sizeof(namespaces[0]);
+	int fds[count];
+	char nspath[1024];
+
+	// 加入mnt命名空间后宿主机的/proc不再可见，因此先打开全部命名空间文件
+	for (int i = 0; i < count; i++) {
+		snprintf(nspath, sizeof(nspath), "/proc/%s/ns/%s", basin_pid, namespaces[i]);
+		fds[i] = open(nspath, O_RDONLY);
+		if (fds[i] < 0) {
+			fprintf(stderr, "open %s failed: %s\n", nspath, strerror(errno));
+			exit(1);
+		}
+	}
+
+	for (int i = 0; i < count; i++) {
+		if (setns(fds[i], 0) == -1) {
+			fprintf(stderr, "setns %s namespace failed: %s\n", namespaces[i], strerror(errno));
+			exit(1);
+		}
+		close(fds[i]);
+	}
+
+	// 加入pid命名空间只对子进程生效，且此后不能再创建线程，因此fork出子进程继续执行Go运行时
+	pid_t child = fork();
+	if (child < 0) {
+		fprintf(stderr, "fork failed: %s\n", strerror(errno));
+		exit(1);
+	}
+	if (child == 0) {
+		return;
+	}
+
+	int status;
+	while (waitpid(child, &status, 0) < 0) {
+		if (errno != EINTR) {
+			fprintf(stderr, "waitpid failed: %s\n", strerror(errno));
+			exit(1);
+		}
+	}
+	if (WIFSIGNALED(status)) {
+		exit(128 + WTERMSIG(status));
+	}
+	exit(WEXITSTATUS(status));
+}
+*/
+import "C"